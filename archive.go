@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+//go:embed archive.html
+var archiveTemplate string
+
+// archiveTimeFormat is the timestamp in archived gallery file names. It sorts
+// lexically in time order.
+const archiveTimeFormat = "20060102T150405Z"
+
+const (
+	archivePrefix = "gallery-"
+	archiveSuffix = ".html"
+)
+
+// archivedGallery is one gallery in an archive directory.
+type archivedGallery struct {
+	Name      string
+	Generated time.Time
+}
+
+// archivePage is the data passed to the archive index template.
+type archivePage struct {
+	Lang      string
+	Title     string
+	Galleries []archivedGallery
+}
+
+// archiveGallery writes the gallery to a timestamped file in dir, then
+// regenerates dir's archive.html index in l's language. It returns the
+// gallery's path.
+func archiveGallery(ctx context.Context, t *template.Template, data pageData, l *locale, dir string, now time.Time) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	path := filepath.Join(dir, archivePrefix+now.UTC().Format(archiveTimeFormat)+archiveSuffix)
+	if err := generateHTML(ctx, fileSink{force: true}, t, data, path); err != nil {
+		return "", err
+	}
+
+	galleries, err := listArchive(dir)
+	if err != nil {
+		return "", err
+	}
+	index := template.Must(template.New("archive").Funcs(templateFuncs(l)).Parse(archiveTemplate))
+	err = writeOutput(ctx, fileSink{}, filepath.Join(dir, "archive.html"), "text/html; charset=utf-8", func(w io.Writer) error {
+		if err := index.Execute(w, archivePage{Lang: l.Tag, Title: data.Title, Galleries: galleries}); err != nil {
+			return fmt.Errorf("failed to execute archive template: %w", err)
+		}
+		return nil
+	})
+	return path, err
+}
+
+// listArchive returns the archived galleries in dir, newest first. Files
+// whose names don't carry a valid timestamp are ignored.
+func listArchive(dir string) ([]archivedGallery, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive directory: %w", err)
+	}
+
+	var galleries []archivedGallery
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, archivePrefix) || !strings.HasSuffix(name, archiveSuffix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, archivePrefix), archiveSuffix)
+		generated, err := time.Parse(archiveTimeFormat, stamp)
+		if err != nil {
+			continue
+		}
+		galleries = append(galleries, archivedGallery{Name: name, Generated: generated})
+	}
+
+	sort.Slice(galleries, func(i, j int) bool {
+		return galleries[i].Generated.After(galleries[j].Generated)
+	})
+	return galleries, nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"lakeview/feed"
+)
+
+// Config is the contents of a -config file. Flags given on the command line
+// take precedence over its values.
+//
+//	out: /var/www/lakes/index.html
+//	title: Great Lakes Live Photos
+//	limit: 100
+//	timeout: 20s
+//	refresh: 15m
+//	feeds:
+//	  - url: https://mastodon.social/@livelakehuron.rss
+//	    name: Lake Huron
+//	    emoji: "🌊"
+//	    color: "#1e88e5"
+//	  - url: https://private.example/@cam.rss
+//	    auth: TOKEN
+type Config struct {
+	Feeds []FeedConfig `yaml:"feeds"`
+	Out   string       `yaml:"out"`
+	Title string       `yaml:"title"`
+	Limit *int         `yaml:"limit"`
+	// Timeout and Refresh are durations such as "30s" or "15m".
+	Timeout string `yaml:"timeout"`
+	Refresh string `yaml:"refresh"`
+}
+
+// FeedConfig is a feed in a config file.
+type FeedConfig struct {
+	URL   string `yaml:"url"`
+	Name  string `yaml:"name"`
+	Color string `yaml:"color"`
+	// Emoji is shown before the name in the caption overlaid on the feed's
+	// photos.
+	Emoji string `yaml:"emoji"`
+	// Auth is a bearer token, or USER:PASSWORD for basic auth.
+	Auth string `yaml:"auth"`
+}
+
+// loadConfig reads a YAML config file. Unknown keys are an error, so typos
+// don't go unnoticed.
+func loadConfig(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer f.Close()
+
+	var cfg Config
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	for i, fc := range cfg.Feeds {
+		if fc.URL == "" {
+			return nil, fmt.Errorf("config file feed %d has no url", i+1)
+		}
+		if fc.Color != "" && !cssColor.MatchString(fc.Color) {
+			return nil, fmt.Errorf("config file feed %d: invalid color %q", i+1, fc.Color)
+		}
+	}
+	return &cfg, nil
+}
+
+// applyTo sets the flags in fs that correspond to cfg's values, skipping
+// flags that were set on the command line.
+func (cfg *Config) applyTo(fs *flag.FlagSet) error {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	values := map[string]string{
+		"out":     cfg.Out,
+		"title":   cfg.Title,
+		"timeout": cfg.Timeout,
+		"refresh": cfg.Refresh,
+	}
+	if cfg.Limit != nil {
+		values["limit"] = strconv.Itoa(*cfg.Limit)
+	}
+
+	for name, v := range values {
+		if v == "" || set[name] {
+			continue
+		}
+		if err := fs.Set(name, v); err != nil {
+			return fmt.Errorf("invalid %s in config file: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// feedURLs returns the URLs of the configured feeds.
+func (cfg *Config) feedURLs() []string {
+	var urls []string
+	for _, fc := range cfg.Feeds {
+		urls = append(urls, fc.URL)
+	}
+	return urls
+}
+
+// styles returns the display names, colors, and emoji of the configured
+// feeds.
+func (cfg *Config) styles() map[string]feedStyle {
+	styles := make(map[string]feedStyle)
+	for _, fc := range cfg.Feeds {
+		style := feedStyle{Name: fc.Name, Color: fc.Color, Emoji: strings.TrimSpace(fc.Emoji)}
+		if style != (feedStyle{}) {
+			styles[fc.URL] = style
+		}
+	}
+	return styles
+}
+
+// credentials returns credentials for the configured feeds that have auth.
+func (cfg *Config) credentials() []feed.Credential {
+	var creds []feed.Credential
+	for _, fc := range cfg.Feeds {
+		if fc.Auth == "" {
+			continue
+		}
+		creds = append(creds, newCredential(fc.URL, fc.Auth))
+	}
+	return creds
+}
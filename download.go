@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+
+	"lakeview/feed"
+)
+
+// downloadStats counts the outcome of downloadPhotos.
+type downloadStats struct {
+	Downloaded int
+	Reused     int
+	Failed     int
+}
+
+// downloadPhotos saves each photo's image (and thumbnail, if different) into
+// dir and rewrites the photo's URLs to the local copies, relative to baseDir.
+// Files that already exist are reused rather than downloaded again. Photos
+// whose images can't be downloaded keep their remote URLs.
+func downloadPhotos(ctx context.Context, f *feed.Fetcher, photos []feed.Photo, dir, baseDir string, concurrency int) (downloadStats, error) {
+	var stats downloadStats
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return stats, fmt.Errorf("failed to create download directory: %w", err)
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var mu sync.Mutex
+	local := make(map[string]string)
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for _, p := range photos {
+		for _, imageURL := range []string{p.URL, p.ThumbURL} {
+			mu.Lock()
+			_, seen := local[imageURL]
+			local[imageURL] = ""
+			mu.Unlock()
+			if seen || imageURL == "" {
+				continue
+			}
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+
+				dest := filepath.Join(dir, localImageName(imageURL))
+				reused, err := download(ctx, f, imageURL, dest)
+
+				mu.Lock()
+				defer mu.Unlock()
+				switch {
+				case err != nil:
+					slog.Warn("Error downloading image", "url", imageURL, "error", err)
+					stats.Failed++
+					return
+				case reused:
+					stats.Reused++
+				default:
+					stats.Downloaded++
+				}
+				rel, err := filepath.Rel(baseDir, dest)
+				if err != nil {
+					rel = dest
+				}
+				local[imageURL] = filepath.ToSlash(rel)
+			}()
+		}
+	}
+	wg.Wait()
+
+	for i := range photos {
+		if l := local[photos[i].URL]; l != "" {
+			photos[i].URL = l
+			// Other renditions aren't downloaded, so don't offer them.
+			photos[i].Sizes = nil
+		}
+		if l := local[photos[i].ThumbURL]; l != "" {
+			photos[i].ThumbURL = l
+		}
+	}
+
+	return stats, nil
+}
+
+// localImageName returns a collision-free file name for imageURL, built from
+// a hash of the URL and the URL's file extension.
+func localImageName(imageURL string) string {
+	sum := sha256.Sum256([]byte(imageURL))
+	name := hex.EncodeToString(sum[:16])
+	if u, err := url.Parse(imageURL); err == nil {
+		name += path.Ext(u.Path)
+	}
+	return name
+}
+
+// download saves imageURL to dest unless dest already exists, reporting
+// whether the existing file was reused.
+func download(ctx context.Context, f *feed.Fetcher, imageURL, dest string) (reused bool, err error) {
+	if _, err := os.Stat(dest); err == nil {
+		return true, nil
+	}
+
+	req, err := f.NewRequest(ctx, http.MethodGet, imageURL)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return false, &feed.StatusError{URL: imageURL, StatusCode: resp.StatusCode}
+	}
+
+	return false, writeFileAtomic(dest, func(w io.Writer) error {
+		if _, err := io.Copy(w, resp.Body); err != nil {
+			return fmt.Errorf("failed to read image: %w", err)
+		}
+		return nil
+	})
+}
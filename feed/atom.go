@@ -0,0 +1,88 @@
+package feed
+
+import "strings"
+
+type Atom struct {
+	Title   string       `xml:"title"`
+	Authors []AtomPerson `xml:"author"`
+	Links   []AtomLink   `xml:"link"`
+	Entries []AtomEntry  `xml:"entry"`
+}
+
+type AtomEntry struct {
+	Title          string           `xml:"title"`
+	Authors        []AtomPerson     `xml:"author"`
+	Published      string           `xml:"published"`
+	Updated        string           `xml:"updated"`
+	Summary        string           `xml:"summary"`
+	Content        string           `xml:"http://www.w3.org/2005/Atom content"`
+	Links          []AtomLink       `xml:"link"`
+	MediaContent   []MediaContent   `xml:"http://search.yahoo.com/mrss/ content"`
+	MediaThumbnail []MediaThumbnail `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+	MediaGroup     []MediaGroup     `xml:"http://search.yahoo.com/mrss/ group"`
+}
+
+type AtomPerson struct {
+	Name string `xml:"name"`
+}
+
+type AtomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+// toChannel maps an Atom feed onto the RSS model so both formats share the
+// same Photo conversion. Image enclosure links become image MediaContent.
+func (a Atom) toChannel() Channel {
+	ch := Channel{Title: a.Title}
+	if len(a.Authors) > 0 {
+		ch.Creator = a.Authors[0].Name
+	}
+	for _, link := range a.Links {
+		if link.Rel == "" || link.Rel == "alternate" {
+			ch.Links = append(ch.Links, link.Href)
+		}
+	}
+
+	for _, entry := range a.Entries {
+		item := Item{
+			Title:          entry.Title,
+			Description:    entry.Summary,
+			PubDate:        entry.Published,
+			MediaContent:   entry.MediaContent,
+			MediaThumbnail: entry.MediaThumbnail,
+			MediaGroup:     entry.MediaGroup,
+		}
+		if len(entry.Authors) > 0 {
+			item.Creator = entry.Authors[0].Name
+		}
+		if item.Description == "" {
+			item.Description = entry.Content
+		}
+		if item.PubDate == "" {
+			item.PubDate = entry.Updated
+		}
+
+		for _, link := range entry.Links {
+			switch link.Rel {
+			case "", "alternate":
+				if item.Link == "" {
+					item.Link = link.Href
+				}
+			case "enclosure":
+				if strings.HasPrefix(link.Type, "image/") {
+					item.MediaContent = append(item.MediaContent, MediaContent{
+						URL:    link.Href,
+						Type:   link.Type,
+						Medium: "image",
+					})
+				}
+			}
+		}
+
+		ch.Items = append(ch.Items, item)
+	}
+
+	return ch
+}
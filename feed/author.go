@@ -0,0 +1,57 @@
+package feed
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+// itemAuthor returns the plain-text name of an item's author, from its
+// author element or its Dublin Core dc:creator, which many feeds, including
+// Mastodon's, use instead. RSS's author element holds an email address
+// optionally followed by a name in parentheses; only the name is used when
+// there is one.
+func itemAuthor(item Item) string {
+	if author := plainText(item.Author); author != "" {
+		if _, name, ok := strings.Cut(author, "("); ok {
+			if name = strings.TrimSpace(strings.TrimSuffix(name, ")")); name != "" {
+				return name
+			}
+		}
+		return author
+	}
+	return plainText(item.Creator)
+}
+
+// channelAuthor returns the author to credit for a channel's items that
+// don't name their own, and a link to the author's profile if known. A
+// channel-level dc:creator or managingEditor is used if present; otherwise,
+// for a Mastodon-style account feed, whose channel link or feed URL is a
+// profile such as https://host/@user, it's the account's handle, @user@host.
+func channelAuthor(ch Channel, feedURL string) (name, link string) {
+	if name := itemAuthor(Item{Author: ch.ManagingEditor, Creator: ch.Creator}); name != "" {
+		return name, ""
+	}
+	for _, u := range append(ch.Links, feedURL) {
+		if handle, profile, ok := accountHandle(u); ok {
+			return handle, profile
+		}
+	}
+	return "", ""
+}
+
+// accountHandle returns the @user@host handle and profile URL of the
+// account whose profile or profile feed is at rawURL, if its path is a
+// single @user segment, optionally with a .rss extension.
+func accountHandle(rawURL string) (handle, profile string, ok bool) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", "", false
+	}
+	user := strings.TrimSuffix(strings.Trim(u.Path, "/"), ".rss")
+	if len(user) < 2 || user[0] != '@' || strings.ContainsAny(user[1:], "/@") {
+		return "", "", false
+	}
+	profile = (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: path.Join("/", user)}).String()
+	return user + "@" + u.Host, profile, true
+}
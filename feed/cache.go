@@ -0,0 +1,153 @@
+package feed
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Cache stores the last successfully parsed response for each feed on disk
+// so it can be revalidated with a conditional request, and used in place of
+// the feed if it can't be fetched.
+type Cache struct {
+	dir string
+}
+
+// cacheEntry is a cached feed response and the validators needed to
+// revalidate it.
+type cacheEntry struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	Body         string `json:"body"`
+	// Fetched is when the response was last fetched or revalidated.
+	Fetched time.Time `json:"fetched"`
+	// Failures is the number of consecutive failed fetches since then, the
+	// last of which was at LastFailure.
+	Failures    int       `json:"failures,omitempty"`
+	LastFailure time.Time `json:"lastFailure"`
+}
+
+// NewCache returns a Cache that stores responses in dir, creating it if
+// necessary.
+func NewCache(dir string) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return &Cache{dir: dir}, nil
+}
+
+func (c *Cache) path(feedURL string) string {
+	sum := sha256.Sum256([]byte(feedURL))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// load returns the cached entry for feedURL, or nil if there isn't a usable
+// one.
+func (c *Cache) load(feedURL string) *cacheEntry {
+	data, err := os.ReadFile(c.path(feedURL))
+	if err != nil {
+		return nil
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.URL != feedURL {
+		return nil
+	}
+	return &entry
+}
+
+// store writes entry via a temporary file so a concurrent load never sees a
+// partial entry.
+func (c *Cache) store(entry *cacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), c.path(entry.URL))
+}
+
+// cachedPhotos returns the photos from the cached copy of feedURL, marked as
+// Cached, and when it was fetched. It returns false if f has no cache or
+// f.CacheMaxAge isn't positive, or there's no cached copy fetched within
+// f.CacheMaxAge.
+func (f *Fetcher) cachedPhotos(feedURL string) ([]Photo, time.Time, bool) {
+	if f.Cache == nil || f.CacheMaxAge <= 0 {
+		return nil, time.Time{}, false
+	}
+	entry := f.Cache.load(feedURL)
+	if entry == nil || time.Since(entry.Fetched) > f.CacheMaxAge {
+		return nil, time.Time{}, false
+	}
+
+	photos, err := f.Parse([]byte(entry.Body), feedURL)
+	if err != nil {
+		return nil, time.Time{}, false
+	}
+	for i := range photos {
+		photos[i].Cached = true
+	}
+	return photos, entry.Fetched, true
+}
+
+// recordFailure counts a failed fetch of feedURL at now, returning the number
+// of consecutive failures.
+func (c *Cache) recordFailure(feedURL string, now time.Time) (int, error) {
+	entry := c.load(feedURL)
+	if entry == nil {
+		entry = &cacheEntry{URL: feedURL}
+	}
+	entry.Failures++
+	entry.LastFailure = now
+	return entry.Failures, c.store(entry)
+}
+
+// disabledUntil returns when feedURL's cooldown ends, if it has failed at
+// least f.DisableAfter times in a row and the cooldown hasn't ended yet.
+func (f *Fetcher) disabledUntil(feedURL string, now time.Time) (time.Time, bool) {
+	if f.Cache == nil || f.DisableAfter <= 0 {
+		return time.Time{}, false
+	}
+	entry := f.Cache.load(feedURL)
+	if entry == nil || entry.Failures < f.DisableAfter {
+		return time.Time{}, false
+	}
+	until := entry.LastFailure.Add(f.DisableCooldown)
+	return until, now.Before(until)
+}
+
+// recordFailure counts a failed fetch of feedURL if f tracks failures, and
+// logs when the feed reaches f.DisableAfter consecutive failures.
+func (f *Fetcher) recordFailure(feedURL string) {
+	if f.Cache == nil || f.DisableAfter <= 0 {
+		return
+	}
+	now := time.Now()
+	n, err := f.Cache.recordFailure(feedURL, now)
+	if err != nil {
+		slog.Warn("Error recording feed failure", "feed", feedURL, "error", err)
+		return
+	}
+	if n >= f.DisableAfter {
+		slog.Warn("Temporarily disabling feed after consecutive failures", "feed", feedURL, "failures", n, "until", now.Add(f.DisableCooldown))
+	}
+}
@@ -0,0 +1,70 @@
+package feed
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// A feed that can't be fetched is reported with one of the error types
+// below, which errors.As can tell apart, except that FetchAll reports a feed
+// it skipped with ErrFeedDisabled or the context's error.
+
+// FetchError reports a feed that couldn't be retrieved: a network failure, or
+// a response that couldn't be read. Its message leaves out URL, since a
+// network error from the HTTP client already names it.
+type FetchError struct {
+	URL string
+	Err error
+}
+
+func (e *FetchError) Error() string {
+	return fmt.Sprintf("failed to fetch feed: %v", e.Err)
+}
+
+func (e *FetchError) Unwrap() error {
+	return e.Err
+}
+
+// ParseError reports a feed that was retrieved but isn't valid RSS or Atom.
+type ParseError struct {
+	URL string
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s: %v", e.URL, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// StatusError reports a non-2xx response.
+type StatusError struct {
+	URL        string
+	StatusCode int
+	// RetryAfter is the delay requested by a 429 response's Retry-After
+	// header, if any.
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s returned status %d %s", e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// ContentError reports a successful response whose body isn't a feed, such as
+// an HTML error page served with a 200 by a misconfigured proxy.
+type ContentError struct {
+	URL string
+	// ContentType is the response's Content-Type header, if any.
+	ContentType string
+}
+
+func (e *ContentError) Error() string {
+	ct := e.ContentType
+	if ct == "" {
+		ct = "no content type"
+	}
+	return fmt.Sprintf("%s returned something other than a feed (%s)", e.URL, ct)
+}
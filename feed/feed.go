@@ -0,0 +1,338 @@
+// Package feed fetches RSS and Atom feeds and extracts the photos they
+// contain.
+package feed
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type RSS struct {
+	Channel Channel `xml:"channel"`
+}
+
+type Channel struct {
+	Title string `xml:"title"`
+	// Links holds the channel's link and any atom:link elements, which
+	// share its local name.
+	Links          []string `xml:"link"`
+	ManagingEditor string   `xml:"managingEditor"`
+	Creator        string   `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Items          []Item   `xml:"item"`
+}
+
+type Item struct {
+	Title          string           `xml:"title"`
+	Description    string           `xml:"description"`
+	PubDate        string           `xml:"pubDate"`
+	Link           string           `xml:"link"`
+	Author         string           `xml:"author"`
+	Creator        string           `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	MediaContent   []MediaContent   `xml:"http://search.yahoo.com/mrss/ content"`
+	MediaThumbnail []MediaThumbnail `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+	MediaGroup     []MediaGroup     `xml:"http://search.yahoo.com/mrss/ group"`
+	Enclosure      []Enclosure      `xml:"enclosure"`
+}
+
+// MediaGroup is a media:group, which wraps alternative renditions of the same
+// media. Its media:content is merged into the item's by flattenMediaGroups.
+type MediaGroup struct {
+	MediaContent   []MediaContent   `xml:"http://search.yahoo.com/mrss/ content"`
+	MediaThumbnail []MediaThumbnail `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+	Description    string           `xml:"http://search.yahoo.com/mrss/ description"`
+}
+
+// Enclosure is a standard RSS enclosure. Image enclosures are used when an
+// item has no image media:content.
+type Enclosure struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length int64  `xml:"length,attr"`
+}
+
+type MediaContent struct {
+	URL         string           `xml:"url,attr" json:"url"`
+	Type        string           `xml:"type,attr" json:"type,omitempty"`
+	Medium      string           `xml:"medium,attr" json:"-"`
+	Width       int              `xml:"width,attr" json:"width,omitempty"`
+	Height      int              `xml:"height,attr" json:"height,omitempty"`
+	Description string           `xml:"http://search.yahoo.com/mrss/ description" json:"-"`
+	Thumbnails  []MediaThumbnail `xml:"http://search.yahoo.com/mrss/ thumbnail" json:"-"`
+}
+
+type MediaThumbnail struct {
+	URL string `xml:"url,attr"`
+}
+
+// DefaultImageFormats is the order in which image formats are preferred when
+// an item offers an image in more than one format.
+var DefaultImageFormats = []string{"image/avif", "image/webp"}
+
+// parseChannel parses an RSS or Atom feed into a Channel.
+func parseChannel(body []byte) (Channel, error) {
+	root, err := rootElement(body)
+	if err != nil {
+		return Channel{}, fmt.Errorf("failed to parse feed: %w", err)
+	}
+
+	switch root {
+	case "rss":
+		var rss RSS
+		if err := unmarshalFeed(body, &rss); err != nil {
+			return Channel{}, fmt.Errorf("failed to parse RSS: %w", err)
+		}
+		return rss.Channel, nil
+	case "feed":
+		var atom Atom
+		if err := unmarshalFeed(body, &atom); err != nil {
+			return Channel{}, fmt.Errorf("failed to parse Atom: %w", err)
+		}
+		return atom.toChannel(), nil
+	default:
+		return Channel{}, fmt.Errorf("unrecognized feed root element <%s>", root)
+	}
+}
+
+// rootElement returns the local name of the document's root element.
+func rootElement(body []byte) (string, error) {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return "", err
+		}
+		if se, ok := tok.(xml.StartElement); ok {
+			return se.Name.Local, nil
+		}
+	}
+}
+
+// channelPhotos converts each image and video in ch into a Photo. Only media
+// with http or https URLs are kept; relative URLs are resolved against
+// feedURL. A photo whose link is missing or unsafe links to its image. When an
+// item offers its image in more than one format, only the images in the
+// format that comes first in formats are used. Items that don't name an
+// author are credited to the channel's author, if it has one.
+func channelPhotos(ch Channel, feedURL string, formats []string) []Photo {
+	source := feedSource(ch.Title, feedURL)
+	chAuthor, chAuthorURL := channelAuthor(ch, feedURL)
+	var photos []Photo
+
+	for _, item := range ch.Items {
+		item = sanitizeItem(flattenMediaGroups(item), feedURL)
+		published, err := ParsePubDate(item.PubDate)
+		if err != nil {
+			slog.Debug("Unparseable pubDate", "feed", feedURL, "item", item.Link, "error", err)
+		}
+		author, authorURL := itemAuthor(item), ""
+		if author == "" {
+			author, authorURL = chAuthor, chAuthorURL
+		}
+
+		var images []MediaContent
+		for _, media := range item.MediaContent {
+			switch media.Medium {
+			case MediaImage:
+				images = append(images, media)
+			case MediaVideo:
+				photos = append(photos, Photo{
+					MediaType:   MediaVideo,
+					URL:         media.URL,
+					ThumbURL:    posterURL(media, item),
+					PubDate:     item.PubDate,
+					Link:        cmp.Or(item.Link, media.URL),
+					Source:      source,
+					FeedURL:     feedURL,
+					Alt:         altText(media, item),
+					Title:       plainText(item.Title),
+					Description: plainText(item.Description),
+					Author:      author,
+					AuthorURL:   authorURL,
+					Width:       media.Width,
+					Height:      media.Height,
+					Published:   published,
+				})
+			}
+		}
+		if len(images) == 0 {
+			images = enclosureImages(item.Enclosure)
+		}
+		if len(images) == 0 {
+			continue
+		}
+		images = preferredImages(images, formats)
+
+		// The item's images are treated as renditions of a single photo;
+		// the widest is used as the full-size image.
+		media := widestImage(images)
+		photos = append(photos, Photo{
+			MediaType:   MediaImage,
+			URL:         media.URL,
+			ThumbURL:    thumbnailURL(media, item),
+			PubDate:     item.PubDate,
+			Link:        cmp.Or(item.Link, media.URL),
+			Source:      source,
+			FeedURL:     feedURL,
+			Alt:         altText(media, item),
+			Title:       plainText(item.Title),
+			Description: plainText(item.Description),
+			Author:      author,
+			AuthorURL:   authorURL,
+			Width:       media.Width,
+			Height:      media.Height,
+			Sizes:       imageSizes(images),
+			Published:   published,
+		})
+	}
+
+	// If no date in the feed parses, the feed most likely uses a format we
+	// don't understand. Fall back to the feed's own order, which is usually
+	// newest first, rather than sinking all of its photos to the bottom.
+	if len(photos) > 0 && allUndated(photos) {
+		slog.Warn("No pubDate in feed could be parsed; ordering its photos by position", "feed", feedURL, "pubDate", photos[0].PubDate)
+		for i := range photos {
+			photos[i].FeedOrder = i + 1
+		}
+	}
+
+	return photos
+}
+
+// allUndated reports whether none of photos has a parsed date.
+func allUndated(photos []Photo) bool {
+	for _, p := range photos {
+		if !p.Published.IsZero() {
+			return false
+		}
+	}
+	return true
+}
+
+// enclosureImages returns the image enclosures as image MediaContent.
+func enclosureImages(enclosures []Enclosure) []MediaContent {
+	var images []MediaContent
+	for _, e := range enclosures {
+		if strings.HasPrefix(e.Type, "image/") && e.URL != "" {
+			images = append(images, MediaContent{URL: e.URL, Type: e.Type, Medium: MediaImage})
+		}
+	}
+	return images
+}
+
+// preferredImages returns the images whose type comes earliest in formats.
+// Images of types not in formats, including those without a type, rank
+// after every listed format and equally with each other.
+func preferredImages(images []MediaContent, formats []string) []MediaContent {
+	rank := func(img MediaContent) int {
+		for i, f := range formats {
+			if strings.EqualFold(img.Type, f) {
+				return i
+			}
+		}
+		return len(formats)
+	}
+
+	best := len(formats)
+	for _, img := range images {
+		best = min(best, rank(img))
+	}
+	if best == len(formats) {
+		return images
+	}
+
+	var preferred []MediaContent
+	for _, img := range images {
+		if rank(img) == best {
+			preferred = append(preferred, img)
+		}
+	}
+	return preferred
+}
+
+// widestImage returns the image with the greatest width, or the first image
+// if none has a known width.
+func widestImage(images []MediaContent) MediaContent {
+	widest := images[0]
+	for _, img := range images[1:] {
+		if img.Width > widest.Width {
+			widest = img
+		}
+	}
+	return widest
+}
+
+// imageSizes returns the images with a known width, narrowest first, or nil
+// if there are fewer than two such images.
+func imageSizes(images []MediaContent) []MediaContent {
+	var sizes []MediaContent
+	for _, img := range images {
+		if img.Width > 0 {
+			sizes = append(sizes, img)
+		}
+	}
+	if len(sizes) < 2 {
+		return nil
+	}
+
+	sort.SliceStable(sizes, func(i, j int) bool { return sizes[i].Width < sizes[j].Width })
+	return sizes
+}
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// altText returns a plain-text caption for media, preferring its
+// media:description over the item's description.
+func altText(media MediaContent, item Item) string {
+	if alt := plainText(media.Description); alt != "" {
+		return alt
+	}
+	return plainText(item.Description)
+}
+
+// plainText strips HTML tags and entities from s and collapses whitespace.
+func plainText(s string) string {
+	s = htmlTag.ReplaceAllString(s, " ")
+	return strings.Join(strings.Fields(html.UnescapeString(s)), " ")
+}
+
+// thumbnailURL returns the thumbnail for media, preferring one nested in the
+// media:content element over one at the item level, and falling back to the
+// full image URL.
+func thumbnailURL(media MediaContent, item Item) string {
+	for _, thumbs := range [][]MediaThumbnail{media.Thumbnails, item.MediaThumbnail} {
+		for _, t := range thumbs {
+			if t.URL != "" {
+				return t.URL
+			}
+		}
+	}
+	return media.URL
+}
+
+// posterURL returns the first thumbnail of a video, or "" if it has none.
+func posterURL(media MediaContent, item Item) string {
+	if u := thumbnailURL(media, item); u != media.URL {
+		return u
+	}
+	return ""
+}
+
+// feedSource returns the channel title, or the feed URL's host if the feed
+// has no title.
+func feedSource(title, feedURL string) string {
+	if title = strings.TrimSpace(title); title != "" {
+		return title
+	}
+	if u, err := url.Parse(feedURL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return feedURL
+}
@@ -0,0 +1,573 @@
+package feed
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Fetcher fetches feeds with a shared HTTP client and retry policy.
+type Fetcher struct {
+	Client *http.Client
+	// Cache, if set, is used to make conditional requests.
+	Cache *Cache
+	// CacheMaxAge is how old a cached feed can be and still be used in
+	// place of the feed when it can't be fetched; 0 disables the fallback.
+	CacheMaxAge time.Duration
+	// DisableAfter, if positive, is the number of consecutive failures,
+	// tracked in the cache, after which a feed is skipped until
+	// DisableCooldown has passed since its last failure.
+	DisableAfter    int
+	DisableCooldown time.Duration
+	UserAgent       string
+	Retries         int
+	RetryDelay      time.Duration
+	// MaxBodySize limits the size of a feed response; 0 means no limit.
+	MaxBodySize int64
+	// Credentials are attached to requests for matching URLs.
+	Credentials []Credential
+	// IncludeVideo keeps videos from feeds; otherwise only images are kept.
+	IncludeVideo bool
+	// HostInterval is the minimum time between feed requests to the same
+	// host; 0 means no limit. Requests to different hosts aren't delayed.
+	HostInterval time.Duration
+	// ImageFormats is the order in which image formats, as MIME types, are
+	// preferred when an item offers more than one; nil means
+	// DefaultImageFormats, and an empty slice means no preference.
+	ImageFormats []string
+	// Getter, if set, supplies feed bodies in place of HTTP requests, and
+	// the HTTP options above are unused.
+	Getter Getter
+
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+// waitForHost blocks until a request to rawURL's host is allowed by
+// f.HostInterval, or ctx is cancelled.
+func (f *Fetcher) waitForHost(ctx context.Context, rawURL string) error {
+	if f.HostInterval <= 0 {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+
+	f.mu.Lock()
+	if f.limiters == nil {
+		f.limiters = make(map[string]*rate.Limiter)
+	}
+	l, ok := f.limiters[u.Host]
+	if !ok {
+		l = rate.NewLimiter(rate.Every(f.HostInterval), 1)
+		f.limiters[u.Host] = l
+	}
+	f.mu.Unlock()
+
+	return l.Wait(ctx)
+}
+
+// slots limits how many requests FetchAll has in flight at once. A nil slots
+// doesn't limit anything.
+type slots chan struct{}
+
+// acquire blocks until a slot is free or ctx is cancelled.
+func (s slots) acquire(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	select {
+	case s <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s slots) release() {
+	if s != nil {
+		<-s
+	}
+}
+
+// Parse parses an RSS or Atom document, detected by its root element, and
+// returns the photos it contains. feedURL is used to label the photos when
+// the feed has no title. Image formats are preferred in the order of
+// f.ImageFormats, and videos are dropped unless f.IncludeVideo is set.
+func (f *Fetcher) Parse(body []byte, feedURL string) ([]Photo, error) {
+	ch, err := parseChannel(body)
+	if err != nil {
+		return nil, &ParseError{URL: feedURL, Err: err}
+	}
+	photos := channelPhotos(ch, feedURL, f.imageFormats())
+	if f.IncludeVideo {
+		return photos, nil
+	}
+
+	images := photos[:0]
+	for _, p := range photos {
+		if p.MediaType == MediaImage {
+			images = append(images, p)
+		}
+	}
+	return images, nil
+}
+
+func (f *Fetcher) imageFormats() []string {
+	if f.ImageFormats == nil {
+		return DefaultImageFormats
+	}
+	return f.ImageFormats
+}
+
+// Credential authenticates requests to URLs that begin with Prefix. If
+// Username is set, it's sent with Password using basic auth; otherwise Token
+// is sent as a bearer token.
+type Credential struct {
+	Prefix   string
+	Username string
+	Password string
+	Token    string
+}
+
+// credential returns the credential with the longest prefix matching url.
+func (f *Fetcher) credential(url string) (Credential, bool) {
+	var best Credential
+	found := false
+	for _, c := range f.Credentials {
+		if strings.HasPrefix(url, c.Prefix) && (!found || len(c.Prefix) > len(best.Prefix)) {
+			best, found = c, true
+		}
+	}
+	return best, found
+}
+
+// NewRequest creates a request with the headers f sends on every request,
+// including an Authorization header if one of f's credentials matches url.
+func (f *Fetcher) NewRequest(ctx context.Context, method, url string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", f.UserAgent)
+	if c, ok := f.credential(url); ok {
+		if c.Username != "" {
+			req.SetBasicAuth(c.Username, c.Password)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+c.Token)
+		}
+	}
+	return req, nil
+}
+
+// Summary describes the outcome of one FetchAll.
+type Summary struct {
+	Succeeded int
+	Failed    int
+	Feeds     []Result
+}
+
+// Result is the outcome of fetching a single feed.
+type Result struct {
+	URL    string
+	Photos int
+	Err    error
+	// Cached is set if the feed failed and its Photos came from the cache.
+	Cached bool
+}
+
+// FetchAll fetches every feed concurrently, making at most concurrency
+// requests at once. A feed waiting for f.HostInterval doesn't hold up feeds
+// on other hosts. Feeds that fail are logged and counted as failed, and
+// their cached photos are used if f.CacheMaxAge allows; otherwise they're
+// skipped. If ctx is cancelled, feeds that haven't finished are skipped with
+// ctx.Err(). If maxPerFeed is positive, only the newest maxPerFeed photos
+// from each feed are kept. Results are merged in feed order regardless of
+// which fetch finishes first.
+func (f *Fetcher) FetchAll(ctx context.Context, feeds []string, concurrency, maxPerFeed int) ([]Photo, Summary) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([][]Photo, len(feeds))
+	errs := make([]error, len(feeds))
+	cached := make([]bool, len(feeds))
+	sem := make(slots, concurrency)
+	var wg sync.WaitGroup
+
+	for i, feedURL := range feeds {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			var photos []Photo
+			var err error
+			if until, disabled := f.disabledUntil(feedURL, time.Now()); disabled {
+				slog.Warn("Skipping temporarily disabled feed", "feed", feedURL, "until", until)
+				err = ErrFeedDisabled
+			} else {
+				photos, err = f.fetch(ctx, feedURL, sem)
+			}
+			if err != nil {
+				if ctx.Err() != nil {
+					slog.Debug("Skipping feed; fetch cancelled", "feed", feedURL)
+					errs[i] = err
+					return
+				}
+				if !errors.Is(err, ErrFeedDisabled) {
+					slog.Warn("Error fetching feed", "feed", feedURL, "error", err)
+					f.recordFailure(feedURL)
+				}
+				errs[i] = err
+
+				var fetched time.Time
+				if photos, fetched, cached[i] = f.cachedPhotos(feedURL); !cached[i] {
+					return
+				}
+				slog.Warn("Using cached photos for feed", "feed", feedURL, "photos", len(photos), "fetched", fetched)
+			}
+			if maxPerFeed > 0 && len(photos) > maxPerFeed {
+				SortPhotos(photos)
+				photos = photos[:maxPerFeed]
+			}
+			results[i] = photos
+		}()
+	}
+	wg.Wait()
+
+	var allPhotos []Photo
+	var summary Summary
+	for i, photos := range results {
+		if errs[i] != nil {
+			summary.Failed++
+		} else {
+			summary.Succeeded++
+		}
+		summary.Feeds = append(summary.Feeds, Result{URL: feeds[i], Photos: len(photos), Err: errs[i], Cached: cached[i]})
+		allPhotos = append(allPhotos, photos...)
+	}
+	return allPhotos, summary
+}
+
+// Fetch fetches and parses a single feed, retrying with exponential backoff
+// when the failure looks transient. If ctx is cancelled, Fetch returns
+// ctx.Err().
+func (f *Fetcher) Fetch(ctx context.Context, url string) ([]Photo, error) {
+	return f.fetch(ctx, url, nil)
+}
+
+// fetch is Fetch, holding one of sem's slots for each request.
+func (f *Fetcher) fetch(ctx context.Context, url string, sem slots) ([]Photo, error) {
+	var photos []Photo
+	err := f.retry(ctx, url, func() error {
+		var err error
+		photos, err = f.fetchPhotos(ctx, url, sem)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return photos, nil
+}
+
+// Get fetches url over HTTP with the same headers, host limit, retries and
+// size limit as a feed, and returns its body. Unlike Fetch, the body can be
+// any kind of document, and f.Cache and f.Getter aren't used.
+func (f *Fetcher) Get(ctx context.Context, url string) ([]byte, error) {
+	var body []byte
+	err := f.retry(ctx, url, func() error {
+		var err error
+		_, body, err = f.do(ctx, url, nil, nil)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// retry calls try until it succeeds, with exponential backoff between
+// attempts, for as long as the failure looks transient and f.Retries allows.
+// If ctx is cancelled, retry returns ctx.Err().
+func (f *Fetcher) retry(ctx context.Context, url string, try func() error) error {
+	delay := f.RetryDelay
+	for attempt := 0; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := try()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err == nil || attempt >= f.Retries || !isRetryable(err) {
+			return err
+		}
+
+		wait := delay
+		delay *= 2
+		var se *StatusError
+		if errors.As(err, &se) && se.StatusCode == http.StatusTooManyRequests {
+			if se.RetryAfter > maxRetryAfter {
+				slog.Warn("Feed throttled; not waiting for Retry-After", "feed", url, "retryAfter", se.RetryAfter, "max", maxRetryAfter)
+				return err
+			}
+			if se.RetryAfter > 0 {
+				wait = se.RetryAfter
+			}
+			slog.Warn("Feed throttled", "feed", url, "delay", wait, "attempt", attempt+1, "retries", f.Retries)
+		} else {
+			slog.Warn("Retrying feed", "feed", url, "delay", wait, "attempt", attempt+1, "retries", f.Retries, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// maxRetryAfter is the longest Retry-After that Fetch will wait for before
+// retrying a throttled feed.
+const maxRetryAfter = 5 * time.Minute
+
+// parseRetryAfter parses a Retry-After header, given either as a number of
+// seconds or as an HTTP date. It returns 0 if the header is missing or
+// invalid.
+func parseRetryAfter(h string, now time.Time) time.Duration {
+	if h == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(h); err == nil {
+		return max(time.Duration(secs)*time.Second, 0)
+	}
+	if t, err := http.ParseTime(h); err == nil {
+		return max(t.Sub(now), 0)
+	}
+	return 0
+}
+
+// looksLikeFeed reports whether body starts like an RSS or Atom document.
+// Feeds are often served with a generic or wrong Content-Type, so only the
+// body is checked; the header is just reported in the error.
+func looksLikeFeed(body []byte) bool {
+	body = bytes.TrimPrefix(body, []byte("\xef\xbb\xbf"))
+	body = bytes.TrimLeft(body, " \t\r\n")
+	for _, prefix := range []string{"<?xml", "<rss", "<feed", "<!--"} {
+		if bytes.HasPrefix(body, []byte(prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// readBody reads r, failing if it's larger than f.MaxBodySize.
+func (f *Fetcher) readBody(r io.Reader) ([]byte, error) {
+	if f.MaxBodySize > 0 {
+		r = io.LimitReader(r, f.MaxBodySize+1)
+	}
+
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if f.MaxBodySize > 0 && int64(len(body)) > f.MaxBodySize {
+		return nil, fmt.Errorf("response exceeds %d bytes", f.MaxBodySize)
+	}
+	return body, nil
+}
+
+// ErrFeedDisabled is the error for a feed skipped because it has failed too
+// many times in a row; see Fetcher.DisableAfter.
+var ErrFeedDisabled = errors.New("feed temporarily disabled after repeated failures")
+
+// ErrTooManyRedirects is returned, wrapped, when a request is redirected more
+// times than allowed by CheckRedirect.
+var ErrTooManyRedirects = errors.New("too many redirects")
+
+// CheckRedirect returns an http.Client CheckRedirect function that follows
+// at most max redirects, logging each one.
+func CheckRedirect(max int) func(req *http.Request, via []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) > max {
+			return fmt.Errorf("%w: stopped after %d redirects from %s", ErrTooManyRedirects, max, via[0].URL)
+		}
+		slog.Debug("Following redirect", "from", via[len(via)-1].URL, "to", req.URL, "hop", len(via))
+		return nil
+	}
+}
+
+// isRetryable reports whether err is a network error, a 429, or a 5xx
+// response.
+func isRetryable(err error) bool {
+	if errors.Is(err, ErrTooManyRedirects) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.StatusCode >= 500 || se.StatusCode == http.StatusTooManyRequests
+	}
+	var ne net.Error
+	return errors.As(err, &ne)
+}
+
+// fetchPhotos gets a feed from f.Getter, or over HTTP if it's nil, and
+// parses it, holding one of sem's slots while getting it. If f has a cache,
+// a feed fetched over HTTP that parses successfully is stored in it.
+func (f *Fetcher) fetchPhotos(ctx context.Context, feedURL string, sem slots) ([]Photo, error) {
+	if f.Getter != nil {
+		if err := sem.acquire(ctx); err != nil {
+			return nil, &FetchError{URL: feedURL, Err: err}
+		}
+		body, err := f.Getter.Get(ctx, feedURL)
+		sem.release()
+		if err != nil {
+			return nil, &FetchError{URL: feedURL, Err: err}
+		}
+		return f.Parse(body, feedURL)
+	}
+
+	entry, _, err := f.get(ctx, feedURL, sem)
+	if err != nil {
+		return nil, err
+	}
+	photos, err := f.Parse([]byte(entry.Body), feedURL)
+	if err != nil {
+		return nil, err
+	}
+	if f.Cache != nil {
+		entry.Failures = 0
+		if err := f.Cache.store(entry); err != nil {
+			slog.Warn("Error caching feed", "feed", feedURL, "error", err)
+		}
+	}
+	return photos, nil
+}
+
+// get fetches a feed over HTTP and returns it as a cache entry, along with
+// the response's status code, or 0 if there was no response. One of sem's
+// slots is held for the request, once the host limit allows it. If f has a
+// cache, the request is made conditional on the cached response's
+// validators, and the cached entry is reused when the server reports it
+// hasn't changed.
+func (f *Fetcher) get(ctx context.Context, feedURL string, sem slots) (*cacheEntry, int, error) {
+	var cached *cacheEntry
+	if f.Cache != nil {
+		cached = f.Cache.load(feedURL)
+	}
+	header := make(http.Header)
+	if cached != nil {
+		if cached.ETag != "" {
+			header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, body, err := f.do(ctx, feedURL, header, sem)
+	if err != nil {
+		if resp != nil {
+			return nil, resp.StatusCode, err
+		}
+		return nil, 0, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified {
+		slog.Debug("Feed not modified; using cached copy", "feed", feedURL)
+		cached.Fetched = time.Now()
+		return cached, resp.StatusCode, nil
+	}
+
+	if !looksLikeFeed(body) {
+		return nil, resp.StatusCode, &ContentError{URL: feedURL, ContentType: resp.Header.Get("Content-Type")}
+	}
+
+	entry := &cacheEntry{
+		URL:          feedURL,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Body:         string(body),
+		Fetched:      time.Now(),
+	}
+	return entry, resp.StatusCode, nil
+}
+
+// do makes a GET request for rawURL with f's headers plus header, once the
+// host limit allows it and one of sem's slots is free, and reads the body,
+// decompressing it if needed. A 304 response is returned without an error if
+// header makes the request conditional; any other non-2xx response is a
+// StatusError. The response is returned, with its body already closed,
+// whenever there was one.
+func (f *Fetcher) do(ctx context.Context, rawURL string, header http.Header, sem slots) (*http.Response, []byte, error) {
+	req, err := f.NewRequest(ctx, http.MethodGet, rawURL)
+	if err != nil {
+		return nil, nil, &FetchError{URL: rawURL, Err: err}
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	conditional := req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != ""
+
+	if err := f.waitForHost(ctx, rawURL); err != nil {
+		return nil, nil, &FetchError{URL: rawURL, Err: err}
+	}
+	if err := sem.acquire(ctx); err != nil {
+		return nil, nil, &FetchError{URL: rawURL, Err: err}
+	}
+	defer sem.release()
+
+	// Setting Accept-Encoding ourselves turns off the transport's transparent
+	// decompression, so gzip responses are decoded below. This keeps the
+	// behavior the same regardless of how the client's transport is set up.
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return nil, nil, &FetchError{URL: rawURL, Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && conditional {
+		return resp, nil, nil
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		se := &StatusError{URL: rawURL, StatusCode: resp.StatusCode}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			se.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		}
+		return resp, nil, se
+	}
+
+	r := io.Reader(resp.Body)
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return resp, nil, &FetchError{URL: rawURL, Err: fmt.Errorf("failed to decompress response: %w", err)}
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	// The size limit applies to the decompressed body.
+	body, err := f.readBody(r)
+	if err != nil {
+		return resp, nil, &FetchError{URL: rawURL, Err: err}
+	}
+	return resp, body, nil
+}
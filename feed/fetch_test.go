@@ -0,0 +1,251 @@
+package feed
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testRSS = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:media="http://search.yahoo.com/mrss/">
+  <channel>
+    <title>Lake Huron</title>
+    <item>
+      <link>https://example.com/huron/2</link>
+      <pubDate>Mon, 12 Oct 2026 12:00:00 +0000</pubDate>
+      <media:content url="https://example.com/huron-2.jpg" type="image/jpeg" medium="image"/>
+    </item>
+    <item>
+      <link>https://example.com/huron/1</link>
+      <pubDate>Mon, 12 Oct 2026 11:00:00 +0000</pubDate>
+      <enclosure url="https://example.com/huron-1.jpg" type="image/jpeg" length="1000"/>
+    </item>
+  </channel>
+</rss>`
+
+const testAtom = `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Lake Erie</title>
+  <entry>
+    <title>Erie at noon</title>
+    <published>2026-10-12T12:00:00Z</published>
+    <link href="https://example.com/erie/1"/>
+    <link rel="enclosure" type="image/jpeg" href="https://example.com/erie-1.jpg"/>
+  </entry>
+</feed>`
+
+// newTestFetcher returns a Fetcher that doesn't retry, for srv.
+func newTestFetcher(srv *httptest.Server) *Fetcher {
+	return &Fetcher{Client: srv.Client(), UserAgent: "lakeview-test"}
+}
+
+// serveFeeds returns a server that answers each path in feeds with its body,
+// and every other path with a 404.
+func serveFeeds(t *testing.T, feeds map[string]string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := feeds[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestFetch(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    []string
+		wantErr any
+	}{
+		{
+			name: "rss",
+			body: testRSS,
+			want: []string{"https://example.com/huron-2.jpg", "https://example.com/huron-1.jpg"},
+		},
+		{
+			name: "atom",
+			body: testAtom,
+			want: []string{"https://example.com/erie-1.jpg"},
+		},
+		{
+			name: "no items",
+			body: `<rss version="2.0"><channel><title>Empty</title></channel></rss>`,
+		},
+		{
+			name: "items without images",
+			body: `<rss version="2.0"><channel><item><title>Text only</title></item></channel></rss>`,
+		},
+		{
+			name:    "empty body",
+			body:    "",
+			wantErr: new(*ContentError),
+		},
+		{
+			name:    "html page",
+			body:    "<!DOCTYPE html><html><body>Oops</body></html>",
+			wantErr: new(*ContentError),
+		},
+		{
+			name:    "truncated",
+			body:    `<rss version="2.0"><channel><item><title>Cut off`,
+			wantErr: new(*ParseError),
+		},
+		{
+			name:    "unknown root",
+			body:    `<?xml version="1.0"?><opml version="2.0"></opml>`,
+			wantErr: new(*ParseError),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := serveFeeds(t, map[string]string{"/feed": tt.body})
+			photos, err := newTestFetcher(srv).Fetch(context.Background(), srv.URL+"/feed")
+			if tt.wantErr != nil {
+				if !errors.As(err, tt.wantErr) {
+					t.Fatalf("Fetch() error = %v, want %T", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Fetch() error = %v", err)
+			}
+			if len(photos) != len(tt.want) {
+				t.Fatalf("Fetch() returned %d photos, want %d", len(photos), len(tt.want))
+			}
+			for i, p := range photos {
+				if p.URL != tt.want[i] {
+					t.Errorf("photo %d URL = %q, want %q", i, p.URL, tt.want[i])
+				}
+				if p.FeedURL != srv.URL+"/feed" {
+					t.Errorf("photo %d FeedURL = %q, want %q", i, p.FeedURL, srv.URL+"/feed")
+				}
+			}
+		})
+	}
+}
+
+func TestFetchStatusError(t *testing.T) {
+	srv := serveFeeds(t, nil)
+	_, err := newTestFetcher(srv).Fetch(context.Background(), srv.URL+"/missing")
+	var se *StatusError
+	if !errors.As(err, &se) {
+		t.Fatalf("Fetch() error = %v, want *StatusError", err)
+	}
+	if se.StatusCode != http.StatusNotFound {
+		t.Errorf("StatusCode = %d, want %d", se.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestFetchAll(t *testing.T) {
+	srv := serveFeeds(t, map[string]string{
+		"/huron":     testRSS,
+		"/erie":      testAtom,
+		"/empty":     `<rss version="2.0"><channel></channel></rss>`,
+		"/malformed": `<rss><channel><item>`,
+	})
+	feeds := []string{srv.URL + "/erie", srv.URL + "/malformed", srv.URL + "/huron", srv.URL + "/empty", srv.URL + "/missing"}
+
+	photos, summary := newTestFetcher(srv).FetchAll(context.Background(), feeds, 2, 0)
+
+	// Photos are merged in feed order, whichever fetch finishes first.
+	want := []string{"https://example.com/erie-1.jpg", "https://example.com/huron-2.jpg", "https://example.com/huron-1.jpg"}
+	if len(photos) != len(want) {
+		t.Fatalf("FetchAll() returned %d photos, want %d", len(photos), len(want))
+	}
+	for i, p := range photos {
+		if p.URL != want[i] {
+			t.Errorf("photo %d URL = %q, want %q", i, p.URL, want[i])
+		}
+	}
+
+	if summary.Succeeded != 3 || summary.Failed != 2 {
+		t.Errorf("summary = %d succeeded, %d failed; want 3 and 2", summary.Succeeded, summary.Failed)
+	}
+	wantPhotos := []int{1, 0, 2, 0, 0}
+	for i, r := range summary.Feeds {
+		if r.URL != feeds[i] {
+			t.Errorf("result %d URL = %q, want %q", i, r.URL, feeds[i])
+		}
+		if r.Photos != wantPhotos[i] {
+			t.Errorf("result %d Photos = %d, want %d", i, r.Photos, wantPhotos[i])
+		}
+		if failed := r.Err != nil; failed != (i == 1 || i == 4) {
+			t.Errorf("result %d Err = %v", i, r.Err)
+		}
+	}
+}
+
+func TestFetchAllMaxPerFeed(t *testing.T) {
+	srv := serveFeeds(t, map[string]string{"/huron": testRSS})
+	photos, _ := newTestFetcher(srv).FetchAll(context.Background(), []string{srv.URL + "/huron"}, 1, 1)
+	if len(photos) != 1 || photos[0].URL != "https://example.com/huron-2.jpg" {
+		t.Errorf("FetchAll() = %v, want only the newest photo", photos)
+	}
+}
+
+func TestFetchGzip(t *testing.T) {
+	// Padding that compresses well, so the compressed body is far smaller
+	// than the decompressed one.
+	padded := strings.Replace(testRSS, "<channel>", "<channel><!--"+strings.Repeat(" ", 64<<10)+"-->", 1)
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write([]byte(padded))
+	gz.Close()
+
+	var acceptEncoding string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acceptEncoding = r.Header.Get("Accept-Encoding")
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(compressed.Bytes())
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name        string
+		maxBodySize int64
+		wantErr     bool
+	}{
+		{name: "no limit"},
+		{name: "under limit", maxBodySize: int64(len(padded))},
+		// The compressed body fits, but the limit applies after
+		// decompression.
+		{name: "over limit", maxBodySize: int64(compressed.Len()) * 2, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTestFetcher(srv)
+			f.MaxBodySize = tt.maxBodySize
+			photos, err := f.Fetch(context.Background(), srv.URL)
+			if acceptEncoding != "gzip" {
+				t.Errorf("Accept-Encoding = %q, want gzip", acceptEncoding)
+			}
+			if tt.wantErr {
+				var fe *FetchError
+				if !errors.As(err, &fe) {
+					t.Fatalf("Fetch() error = %v, want *FetchError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Fetch() error = %v", err)
+			}
+			if len(photos) != 2 {
+				t.Errorf("Fetch() returned %d photos, want 2", len(photos))
+			}
+		})
+	}
+}
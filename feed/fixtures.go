@@ -0,0 +1,48 @@
+package feed
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Getter gets the raw body of a feed.
+type Getter interface {
+	Get(ctx context.Context, feedURL string) ([]byte, error)
+}
+
+// FixtureDir is a Getter that reads feeds from files in a directory instead
+// of the network. Each feed is read from the file named by FixtureName.
+type FixtureDir string
+
+// Get reads the fixture for feedURL.
+func (d FixtureDir) Get(ctx context.Context, feedURL string) ([]byte, error) {
+	body, err := os.ReadFile(filepath.Join(string(d), FixtureName(feedURL)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture: %w", err)
+	}
+	return body, nil
+}
+
+// FixtureName returns the name of the fixture file for feedURL: the URL
+// without its scheme, with every character other than a letter, digit, dot,
+// or hyphen replaced by an underscore, and ".xml" appended. For example,
+// https://mastodon.social/@livelakeerie.rss is read from
+// mastodon.social__livelakeerie.rss.xml.
+func FixtureName(feedURL string) string {
+	s := feedURL
+	if u, err := url.Parse(feedURL); err == nil && u.Scheme != "" {
+		s = strings.TrimPrefix(s, u.Scheme+"://")
+	}
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9', r == '.', r == '-':
+			return r
+		}
+		return '_'
+	}, s)
+	return strings.Trim(s, "_") + ".xml"
+}
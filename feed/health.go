@@ -0,0 +1,86 @@
+package feed
+
+import (
+	"context"
+	"sync"
+)
+
+// Health is the outcome of checking a single feed with Check.
+type Health struct {
+	URL string
+	// Status is the HTTP status code of the response, or 0 if there was no
+	// response or the feed was read from f.Getter.
+	Status int
+	Items  int
+	Images int
+	Err    error
+}
+
+// OK reports whether the feed was fetched and parsed and has at least one
+// image.
+func (h Health) OK() bool {
+	return h.Err == nil && h.Images > 0
+}
+
+// Check fetches and parses a feed once, without retrying, and reports how
+// many items and images it has.
+func (f *Fetcher) Check(ctx context.Context, feedURL string) Health {
+	return f.check(ctx, feedURL, nil)
+}
+
+// check is Check, holding one of sem's slots for the request.
+func (f *Fetcher) check(ctx context.Context, feedURL string, sem slots) Health {
+	h := Health{URL: feedURL}
+	var body []byte
+	if f.Getter != nil {
+		if h.Err = sem.acquire(ctx); h.Err == nil {
+			body, h.Err = f.Getter.Get(ctx, feedURL)
+			sem.release()
+		}
+		if h.Err != nil {
+			h.Err = &FetchError{URL: feedURL, Err: h.Err}
+		}
+	} else {
+		var entry *cacheEntry
+		if entry, h.Status, h.Err = f.get(ctx, feedURL, sem); h.Err == nil {
+			body = []byte(entry.Body)
+		}
+	}
+	if h.Err != nil {
+		return h
+	}
+
+	ch, err := parseChannel(body)
+	if err != nil {
+		h.Err = &ParseError{URL: feedURL, Err: err}
+		return h
+	}
+	h.Items = len(ch.Items)
+	for _, p := range channelPhotos(ch, feedURL, f.imageFormats()) {
+		if p.MediaType == MediaImage {
+			h.Images++
+		}
+	}
+	return h
+}
+
+// CheckAll checks every feed concurrently, making at most concurrency
+// requests at once, and returns the results in feed order.
+func (f *Fetcher) CheckAll(ctx context.Context, feeds []string, concurrency int) []Health {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]Health, len(feeds))
+	sem := make(slots, concurrency)
+	var wg sync.WaitGroup
+	for i, feedURL := range feeds {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = f.check(ctx, feedURL, sem)
+		}()
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,73 @@
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+)
+
+// mediaNS is the Media RSS namespace that the feed structs are tagged with.
+const mediaNS = "http://search.yahoo.com/mrss/"
+
+// mediaNSVariants are the other namespace URIs, without a scheme or trailing
+// slash, that feeds use for Media RSS elements.
+var mediaNSVariants = map[string]bool{
+	"search.yahoo.com/mrss":       true,
+	"video.search.yahoo.com/mrss": true,
+	"tools.search.yahoo.com/mrss": true,
+}
+
+// normalizeMediaNS returns mediaNS if space is a variant of it, and space
+// otherwise.
+func normalizeMediaNS(space string) string {
+	s := strings.TrimPrefix(strings.TrimPrefix(space, "http://"), "https://")
+	if mediaNSVariants[strings.TrimSuffix(s, "/")] {
+		return mediaNS
+	}
+	return space
+}
+
+// mediaNSReader rewrites the namespace of elements in a Media RSS namespace
+// variant to mediaNS, so the feed structs match them.
+type mediaNSReader struct {
+	d *xml.Decoder
+}
+
+func (r mediaNSReader) Token() (xml.Token, error) {
+	tok, err := r.d.Token()
+	switch t := tok.(type) {
+	case xml.StartElement:
+		t.Name.Space = normalizeMediaNS(t.Name.Space)
+		return t, err
+	case xml.EndElement:
+		t.Name.Space = normalizeMediaNS(t.Name.Space)
+		return t, err
+	}
+	return tok, err
+}
+
+// unmarshalFeed decodes body into v like xml.Unmarshal, but tolerating
+// variants of the Media RSS namespace.
+func unmarshalFeed(body []byte, v any) error {
+	d := xml.NewTokenDecoder(mediaNSReader{xml.NewDecoder(bytes.NewReader(body))})
+	return d.Decode(v)
+}
+
+// flattenMediaGroups moves the media:content of each of item's media:group
+// elements into item.MediaContent. Thumbnails and a description given for a
+// group apply to each of its media that doesn't have its own.
+func flattenMediaGroups(item Item) Item {
+	for _, g := range item.MediaGroup {
+		for _, m := range g.MediaContent {
+			if len(m.Thumbnails) == 0 {
+				m.Thumbnails = g.MediaThumbnail
+			}
+			if m.Description == "" {
+				m.Description = g.Description
+			}
+			item.MediaContent = append(item.MediaContent, m)
+		}
+	}
+	item.MediaGroup = nil
+	return item
+}
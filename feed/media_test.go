@@ -0,0 +1,93 @@
+package feed
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParseMediaGroups(t *testing.T) {
+	// The fixture declares the Media RSS namespace without its trailing
+	// slash and wraps each item's renditions in a media:group.
+	body, err := os.ReadFile("../testdata/fixtures/example.com_lakes_grouped.rss.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	photos, err := new(Fetcher).Parse(body, "https://example.com/lakes/grouped.rss")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	type size struct {
+		url   string
+		width int
+	}
+	want := []struct {
+		url, thumbURL, alt string
+		width, height      int
+		sizes              []size
+	}{
+		{
+			// The widest rendition is shown, and the group's thumbnail
+			// and description apply to each rendition.
+			url:      "https://example.com/media/superior-1-1280.jpg",
+			thumbURL: "https://example.com/media/superior-1-thumb.jpg",
+			alt:      "Dawn over Lake Superior, seen from the Marquette camera",
+			width:    1280,
+			height:   960,
+			sizes: []size{
+				{"https://example.com/media/superior-1-640.jpg", 640},
+				{"https://example.com/media/superior-1-1280.jpg", 1280},
+			},
+		},
+		{
+			// WebP is preferred over a JPEG of the same size.
+			url:      "https://example.com/media/michigan-1.webp",
+			thumbURL: "https://example.com/media/michigan-1.webp",
+			width:    1280,
+			height:   720,
+		},
+	}
+
+	if len(photos) != len(want) {
+		t.Fatalf("Parse() returned %d photos, want %d", len(photos), len(want))
+	}
+	for i, p := range photos {
+		w := want[i]
+		if p.URL != w.url || p.ThumbURL != w.thumbURL {
+			t.Errorf("photo %d URL, ThumbURL = %q, %q; want %q, %q", i, p.URL, p.ThumbURL, w.url, w.thumbURL)
+		}
+		if p.Alt != w.alt {
+			t.Errorf("photo %d Alt = %q, want %q", i, p.Alt, w.alt)
+		}
+		if p.Width != w.width || p.Height != w.height {
+			t.Errorf("photo %d size = %dx%d, want %dx%d", i, p.Width, p.Height, w.width, w.height)
+		}
+		if len(p.Sizes) != len(w.sizes) {
+			t.Errorf("photo %d has %d sizes, want %d", i, len(p.Sizes), len(w.sizes))
+			continue
+		}
+		for j, s := range p.Sizes {
+			if s.URL != w.sizes[j].url || s.Width != w.sizes[j].width {
+				t.Errorf("photo %d size %d = %q at %dw, want %q at %dw", i, j, s.URL, s.Width, w.sizes[j].url, w.sizes[j].width)
+			}
+		}
+	}
+}
+
+func TestNormalizeMediaNS(t *testing.T) {
+	for _, tt := range []struct {
+		space, want string
+	}{
+		{"http://search.yahoo.com/mrss/", mediaNS},
+		{"http://search.yahoo.com/mrss", mediaNS},
+		{"https://search.yahoo.com/mrss/", mediaNS},
+		{"http://video.search.yahoo.com/mrss", mediaNS},
+		{"http://tools.search.yahoo.com/mrss/", mediaNS},
+		{"http://www.w3.org/2005/Atom", "http://www.w3.org/2005/Atom"},
+		{"", ""},
+	} {
+		if got := normalizeMediaNS(tt.space); got != tt.want {
+			t.Errorf("normalizeMediaNS(%q) = %q, want %q", tt.space, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package feed
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Media types of a Photo.
+const (
+	MediaImage = "image"
+	MediaVideo = "video"
+)
+
+type Photo struct {
+	// MediaType is MediaImage or MediaVideo. For a video, URL is the video
+	// and ThumbURL is its poster image, if the feed has one.
+	MediaType string `json:"mediaType"`
+	URL       string `json:"url"`
+	ThumbURL  string `json:"thumbUrl"`
+	PubDate   string `json:"pubDate"`
+	Link      string `json:"link"`
+	Source    string `json:"source"`
+	Color     string `json:"color,omitempty"`
+	// Caption is the display name, with its emoji if any, configured for
+	// the photo's feed, e.g. "🌊 Lake Huron". It's empty if the feed has
+	// none.
+	Caption string `json:"caption,omitempty"`
+	Alt     string `json:"alt,omitempty"`
+	Width   int    `json:"width,omitempty"`
+	Height  int    `json:"height,omitempty"`
+
+	// Title and Description are the plain-text title and description of the
+	// post the photo came from.
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	// Author is the name or account handle of whoever posted the photo, and
+	// AuthorURL links to their profile, if known.
+	Author    string `json:"author,omitempty"`
+	AuthorURL string `json:"authorUrl,omitempty"`
+
+	// Sizes lists the available renditions of the image, narrowest first,
+	// when the feed offers more than one with a known width.
+	Sizes []MediaContent `json:"sizes,omitempty"`
+
+	// Cached is set if the photo came from the cache because its feed
+	// couldn't be fetched, so it may be out of date.
+	Cached bool `json:"cached,omitempty"`
+
+	// New is set if the photo wasn't in the previous run's output.
+	New bool `json:"new,omitempty"`
+
+	// Preload is set for the photos that the HTML page asks the browser to
+	// load early instead of lazily.
+	Preload bool `json:"-"`
+
+	// ImageSizes is the sizes attribute for the image's srcset on the HTML
+	// page, which depends on the page's column layout.
+	ImageSizes string `json:"-"`
+
+	// FeedURL is the URL of the feed the photo came from.
+	FeedURL string `json:"-"`
+
+	// Published is PubDate parsed by ParsePubDate, or the zero time if
+	// PubDate couldn't be parsed.
+	Published time.Time `json:"-"`
+
+	// FeedOrder is the photo's 1-based position in its feed, set only when
+	// none of the feed's dates could be parsed. SortPhotos uses it to
+	// interleave such photos with dated ones instead of sorting them last.
+	FeedOrder int `json:"-"`
+}
+
+// pubDateFormats are the layouts tried, in order, when parsing pubDate.
+var pubDateFormats = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+}
+
+// ParsePubDate parses a pubDate using each of the supported layouts in turn.
+func ParsePubDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range pubDateFormats {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized date format %q", s)
+}
+
+// SortPhotos sorts photos newest first. Photos with a FeedOrder take the date
+// of the dated photo at the same rank, so the n-th photo of a feed with
+// unparseable dates sorts alongside the n-th newest dated photo. Other photos
+// without a parsed date sort last. Photos with the same date are ordered by
+// FeedOrder, URL, and then Link, so the result doesn't depend on the input
+// order.
+func SortPhotos(photos []Photo) {
+	sortPhotos(photos, false)
+}
+
+// SortPhotosOldestFirst sorts photos like SortPhotos, but oldest first.
+// Photos without a parsed date still sort last, and photos with the same date
+// are ordered the same way as by SortPhotos.
+func SortPhotosOldestFirst(photos []Photo) {
+	sortPhotos(photos, true)
+}
+
+func sortPhotos(photos []Photo, oldestFirst bool) {
+	var dated []time.Time
+	for _, p := range photos {
+		if !p.Published.IsZero() {
+			dated = append(dated, p.Published)
+		}
+	}
+	sort.Slice(dated, func(i, j int) bool { return dated[i].After(dated[j]) })
+
+	type keyed struct {
+		key time.Time
+		Photo
+	}
+	ks := make([]keyed, len(photos))
+	for i, p := range photos {
+		ks[i] = keyed{p.Published, p}
+		if p.Published.IsZero() && p.FeedOrder > 0 && len(dated) > 0 {
+			ks[i].key = dated[min(p.FeedOrder, len(dated))-1]
+		}
+	}
+
+	sort.Slice(ks, func(i, j int) bool {
+		ti, tj := ks[i].key, ks[j].key
+		if !ti.Equal(tj) {
+			if oldestFirst && !ti.IsZero() && !tj.IsZero() {
+				return ti.Before(tj)
+			}
+			return ti.After(tj)
+		}
+		if ks[i].FeedOrder != ks[j].FeedOrder {
+			return ks[i].FeedOrder < ks[j].FeedOrder
+		}
+		if ks[i].URL != ks[j].URL {
+			return ks[i].URL < ks[j].URL
+		}
+		return ks[i].Link < ks[j].Link
+	})
+
+	for i := range ks {
+		photos[i] = ks[i].Photo
+	}
+}
@@ -0,0 +1,81 @@
+package feed
+
+import (
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"time"
+)
+
+// samePublished returns photos that mostly share a date, so only the
+// tie-breakers decide their order.
+func samePublished() []Photo {
+	noon := time.Date(2026, 10, 12, 12, 0, 0, 0, time.UTC)
+	return []Photo{
+		{URL: "https://example.com/b.jpg", Link: "https://example.com/post/1", Published: noon},
+		{URL: "https://example.com/a.jpg", Link: "https://example.com/post/2", Published: noon},
+		{URL: "https://example.com/a.jpg", Link: "https://example.com/post/1", Published: noon},
+		{URL: "https://example.com/c.jpg", Link: "https://example.com/post/3", Published: noon},
+		{URL: "https://example.com/d.jpg", Link: "https://example.com/post/4", Published: noon.Add(-time.Hour)},
+		{URL: "https://example.com/e.jpg", Link: "https://example.com/post/5"},
+		{URL: "https://example.com/f.jpg", Link: "https://example.com/post/6"},
+		{URL: "https://example.com/g.jpg", Link: "https://example.com/post/7", FeedOrder: 1},
+	}
+}
+
+func photoKeys(photos []Photo) []string {
+	urls := make([]string, len(photos))
+	for i, p := range photos {
+		urls[i] = p.URL + " " + p.Link
+	}
+	return urls
+}
+
+func TestSortPhotosDeterministic(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		sort func([]Photo)
+		want []string
+	}{
+		{
+			name: "newest first",
+			sort: SortPhotos,
+			want: []string{
+				"https://example.com/a.jpg https://example.com/post/1",
+				"https://example.com/a.jpg https://example.com/post/2",
+				"https://example.com/b.jpg https://example.com/post/1",
+				"https://example.com/c.jpg https://example.com/post/3",
+				"https://example.com/g.jpg https://example.com/post/7",
+				"https://example.com/d.jpg https://example.com/post/4",
+				"https://example.com/e.jpg https://example.com/post/5",
+				"https://example.com/f.jpg https://example.com/post/6",
+			},
+		},
+		{
+			name: "oldest first",
+			sort: SortPhotosOldestFirst,
+			want: []string{
+				"https://example.com/d.jpg https://example.com/post/4",
+				"https://example.com/a.jpg https://example.com/post/1",
+				"https://example.com/a.jpg https://example.com/post/2",
+				"https://example.com/b.jpg https://example.com/post/1",
+				"https://example.com/c.jpg https://example.com/post/3",
+				"https://example.com/g.jpg https://example.com/post/7",
+				"https://example.com/e.jpg https://example.com/post/5",
+				"https://example.com/f.jpg https://example.com/post/6",
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rand.New(rand.NewPCG(1, 2))
+			for i := 0; i < 100; i++ {
+				photos := samePublished()
+				r.Shuffle(len(photos), func(i, j int) { photos[i], photos[j] = photos[j], photos[i] })
+				tt.sort(photos)
+				if got := photoKeys(photos); !slices.Equal(got, tt.want) {
+					t.Fatalf("run %d: order = %q, want %q", i, got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package feed
+
+import (
+	"log/slog"
+	"net/url"
+)
+
+// safeURL resolves raw against base and returns it if the result is an
+// absolute http or https URL. Feed content is untrusted, so anything else,
+// such as a javascript: URL, is rejected before it can reach a page.
+func safeURL(raw string, base *url.URL) (string, bool) {
+	u, err := url.Parse(raw)
+	if err != nil || raw == "" {
+		return "", false
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", false
+	}
+	return u.String(), true
+}
+
+// sanitizeItem returns item with every URL made absolute, dropping media,
+// thumbnails, and enclosures whose URLs aren't safe and clearing an unsafe
+// link.
+func sanitizeItem(item Item, feedURL string) Item {
+	base, err := url.Parse(feedURL)
+	if err != nil {
+		base = nil
+	}
+
+	if item.Link != "" {
+		link, ok := safeURL(item.Link, base)
+		if !ok {
+			slog.Debug("Dropping unsafe link", "feed", feedURL, "url", item.Link)
+		}
+		item.Link = link
+	}
+
+	var media []MediaContent
+	for _, m := range item.MediaContent {
+		u, ok := safeURL(m.URL, base)
+		if !ok {
+			slog.Debug("Dropping media with unsafe URL", "feed", feedURL, "url", m.URL)
+			continue
+		}
+		m.URL = u
+		m.Thumbnails = safeThumbnails(m.Thumbnails, base)
+		media = append(media, m)
+	}
+	item.MediaContent = media
+	item.MediaThumbnail = safeThumbnails(item.MediaThumbnail, base)
+
+	var enclosures []Enclosure
+	for _, e := range item.Enclosure {
+		if u, ok := safeURL(e.URL, base); ok {
+			e.URL = u
+			enclosures = append(enclosures, e)
+		}
+	}
+	item.Enclosure = enclosures
+
+	return item
+}
+
+func safeThumbnails(thumbs []MediaThumbnail, base *url.URL) []MediaThumbnail {
+	var safe []MediaThumbnail
+	for _, t := range thumbs {
+		if u, ok := safeURL(t.URL, base); ok {
+			safe = append(safe, MediaThumbnail{URL: u})
+		}
+	}
+	return safe
+}
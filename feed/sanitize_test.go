@@ -0,0 +1,112 @@
+package feed
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestChannelPhotosSanitizesURLs(t *testing.T) {
+	const feedURL = "https://example.com/lakes/feed.rss"
+	image := func(url string) MediaContent {
+		return MediaContent{URL: url, Type: "image/jpeg", Medium: MediaImage}
+	}
+
+	tests := []struct {
+		name string
+		item Item
+		// want is the photo's URL, ThumbURL, and Link, or nil if the item
+		// should have no photo.
+		want []string
+	}{
+		{
+			name: "absolute",
+			item: Item{Link: "https://example.com/post/1", MediaContent: []MediaContent{image("https://example.com/a.jpg")}},
+			want: []string{"https://example.com/a.jpg", "https://example.com/a.jpg", "https://example.com/post/1"},
+		},
+		{
+			name: "relative path",
+			item: Item{Link: "post/1", MediaContent: []MediaContent{image("images/a.jpg")}},
+			want: []string{"https://example.com/lakes/images/a.jpg", "https://example.com/lakes/images/a.jpg", "https://example.com/lakes/post/1"},
+		},
+		{
+			name: "root-relative",
+			item: Item{Link: "/post/1", MediaContent: []MediaContent{image("/media/a.jpg")}},
+			want: []string{"https://example.com/media/a.jpg", "https://example.com/media/a.jpg", "https://example.com/post/1"},
+		},
+		{
+			name: "protocol-relative",
+			item: Item{MediaContent: []MediaContent{image("//cdn.example.net/a.jpg")}},
+			want: []string{"https://cdn.example.net/a.jpg", "https://cdn.example.net/a.jpg", "https://cdn.example.net/a.jpg"},
+		},
+		{
+			name: "javascript media",
+			item: Item{Link: "https://example.com/post/1", MediaContent: []MediaContent{image("javascript:alert(1)")}},
+		},
+		{
+			name: "javascript media with a safe alternative",
+			item: Item{MediaContent: []MediaContent{image("JavaScript:alert(1)"), image("https://example.com/a.jpg")}},
+			want: []string{"https://example.com/a.jpg", "https://example.com/a.jpg", "https://example.com/a.jpg"},
+		},
+		{
+			name: "data media",
+			item: Item{MediaContent: []MediaContent{image("data:image/png;base64,iVBORw0KGgo=")}},
+		},
+		{
+			name: "other scheme",
+			item: Item{MediaContent: []MediaContent{image("ftp://example.com/a.jpg")}},
+		},
+		{
+			name: "malformed media",
+			item: Item{MediaContent: []MediaContent{image("http://[::1/a.jpg"), image("%zz")}},
+		},
+		{
+			name: "javascript link is cleared",
+			item: Item{Link: "javascript:alert(1)", MediaContent: []MediaContent{image("https://example.com/a.jpg")}},
+			want: []string{"https://example.com/a.jpg", "https://example.com/a.jpg", "https://example.com/a.jpg"},
+		},
+		{
+			name: "malformed link is cleared",
+			item: Item{Link: "http://[::1", MediaContent: []MediaContent{image("https://example.com/a.jpg")}},
+			want: []string{"https://example.com/a.jpg", "https://example.com/a.jpg", "https://example.com/a.jpg"},
+		},
+		{
+			name: "unsafe thumbnails are dropped",
+			item: Item{
+				MediaContent: []MediaContent{{
+					URL: "https://example.com/a.jpg", Type: "image/jpeg", Medium: MediaImage,
+					Thumbnails: []MediaThumbnail{{URL: "javascript:alert(1)"}},
+				}},
+				MediaThumbnail: []MediaThumbnail{{URL: "vbscript:msgbox(1)"}, {URL: "thumbs/a.jpg"}},
+			},
+			want: []string{"https://example.com/a.jpg", "https://example.com/lakes/thumbs/a.jpg", "https://example.com/a.jpg"},
+		},
+		{
+			name: "relative enclosure",
+			item: Item{Enclosure: []Enclosure{{URL: "javascript:alert(1)", Type: "image/jpeg"}, {URL: "a.jpg", Type: "image/jpeg"}}},
+			want: []string{"https://example.com/lakes/a.jpg", "https://example.com/lakes/a.jpg", "https://example.com/lakes/a.jpg"},
+		},
+		{
+			name: "javascript enclosure",
+			item: Item{Enclosure: []Enclosure{{URL: "javascript:alert(1)", Type: "image/jpeg"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			photos := channelPhotos(Channel{Items: []Item{tt.item}}, feedURL, nil)
+			if tt.want == nil {
+				if len(photos) != 0 {
+					t.Fatalf("channelPhotos() = %+v, want no photos", photos)
+				}
+				return
+			}
+			if len(photos) != 1 {
+				t.Fatalf("channelPhotos() returned %d photos, want 1", len(photos))
+			}
+			p := photos[0]
+			if got := []string{p.URL, p.ThumbURL, p.Link}; !slices.Equal(got, tt.want) {
+				t.Errorf("URL, ThumbURL, Link = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"lakeview/feed"
+)
+
+// opml is an OPML document, as exported by most feed readers. Outlines may be
+// nested in folders.
+type opml struct {
+	Outlines []opmlOutline `xml:"body>outline"`
+}
+
+type opmlOutline struct {
+	XMLURL   string        `xml:"xmlUrl,attr"`
+	Text     string        `xml:"text,attr"`
+	Title    string        `xml:"title,attr"`
+	Outlines []opmlOutline `xml:"outline"`
+}
+
+// fetchFeedsList fetches the list of feeds at listURL and parses it with
+// parseFeedsDocument.
+func fetchFeedsList(ctx context.Context, f *feed.Fetcher, listURL string) ([]string, map[string]feedStyle, error) {
+	body, err := f.Get(ctx, listURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	return parseFeedsDocument(body)
+}
+
+// parseFeedsDocument parses a list of feeds that is either an OPML document,
+// whose outline elements' xmlUrl attributes are the feeds and whose text or
+// title attributes are their display names, or a plain-text list in the
+// format read by parseFeedsList.
+func parseFeedsDocument(body []byte) ([]string, map[string]feedStyle, error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(bytes.TrimPrefix(body, []byte("\xef\xbb\xbf"))), []byte("<")) {
+		return parseFeedsList(bytes.NewReader(body))
+	}
+
+	var doc opml
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse OPML: %w", err)
+	}
+
+	var feeds []string
+	styles := make(map[string]feedStyle)
+	var walk func([]opmlOutline)
+	walk = func(outlines []opmlOutline) {
+		for _, o := range outlines {
+			if u := strings.TrimSpace(o.XMLURL); u != "" {
+				feeds = append(feeds, u)
+				if name := strings.TrimSpace(o.Text); name != "" {
+					styles[u] = feedStyle{Name: name}
+				} else if name := strings.TrimSpace(o.Title); name != "" {
+					styles[u] = feedStyle{Name: name}
+				}
+			}
+			walk(o.Outlines)
+		}
+	}
+	walk(doc.Outlines)
+	return feeds, styles, nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+
+	"lakeview/feed"
+)
+
+// inlineWarnSize is the size of embedded image data, in bytes, above which
+// -inline-images warns that the page is large.
+const inlineWarnSize = 5 << 20
+
+// inlineStats counts the outcome of inlinePhotos.
+type inlineStats struct {
+	Inlined int
+	Failed  int
+	// Skipped counts images left remote because they would have pushed the
+	// page over the total size cap.
+	Skipped int
+	// Bytes is the total size of the data URIs embedded in the page.
+	Bytes int
+}
+
+// inlinePhotos downloads each image photo's thumbnail, the image shown in the
+// gallery, and replaces its URL with a data URI, so that the page works on
+// its own with no network. Images larger than maxImage bytes, or that don't
+// download as an image, keep their remote URLs, as do images past the point
+// where the data URIs would exceed maxTotal bytes; photos earlier in the
+// list take priority. Videos aren't inlined. A limit of 0 means no limit.
+func inlinePhotos(ctx context.Context, f *feed.Fetcher, photos []feed.Photo, maxImage, maxTotal int64, concurrency int) inlineStats {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var mu sync.Mutex
+	uris := make(map[string]string)
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for _, p := range photos {
+		if p.MediaType != feed.MediaImage {
+			continue
+		}
+		mu.Lock()
+		_, seen := uris[p.ThumbURL]
+		uris[p.ThumbURL] = ""
+		mu.Unlock()
+		if seen {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			uri, err := fetchDataURI(ctx, f, p.ThumbURL, maxImage)
+			if err != nil {
+				slog.Warn("Error inlining image; keeping its URL", "url", p.ThumbURL, "error", err)
+				return
+			}
+			mu.Lock()
+			uris[p.ThumbURL] = uri
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	var stats inlineStats
+	embedded := make(map[string]bool)
+	for i, p := range photos {
+		if p.MediaType != feed.MediaImage {
+			continue
+		}
+		uri := uris[p.ThumbURL]
+		switch {
+		case uri == "":
+			stats.Failed++
+			continue
+		case !embedded[p.ThumbURL] && maxTotal > 0 && int64(stats.Bytes+len(uri)) > maxTotal:
+			stats.Skipped++
+			continue
+		}
+		if !embedded[p.ThumbURL] {
+			embedded[p.ThumbURL] = true
+			stats.Bytes += len(uri)
+		}
+		photos[i].ThumbURL = uri
+		// Other renditions would still load over the network.
+		photos[i].Sizes = nil
+		stats.Inlined++
+	}
+	return stats
+}
+
+// fetchDataURI downloads imageURL and returns it as a base64 data URI,
+// failing if it's larger than maxBytes or isn't an image.
+func fetchDataURI(ctx context.Context, f *feed.Fetcher, imageURL string, maxBytes int64) (string, error) {
+	req, err := f.NewRequest(ctx, http.MethodGet, imageURL)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", &feed.StatusError{URL: imageURL, StatusCode: resp.StatusCode}
+	}
+
+	r := io.Reader(resp.Body)
+	if maxBytes > 0 {
+		r = io.LimitReader(r, maxBytes+1)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to read image: %w", err)
+	}
+	if maxBytes > 0 && int64(len(data)) > maxBytes {
+		return "", fmt.Errorf("image is larger than %d bytes", maxBytes)
+	}
+
+	mimeType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mimeType, "image/") {
+		mimeType, _, _ = mime.ParseMediaType(http.DetectContentType(data))
+	}
+	if !strings.HasPrefix(mimeType, "image/") {
+		return "", fmt.Errorf("unexpected content type %q", mimeType)
+	}
+	return string(dataURI(mimeType, data)), nil
+}
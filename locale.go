@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// locale holds the strings and formats for one page language. To add a
+// language, add an entry to locales with a translation for each message the
+// templates pass to tr; a missing message is shown in English.
+type locale struct {
+	// Tag is the BCP 47 language tag used in the page's lang attribute.
+	Tag string
+	// DateFormat is the time layout for absolute dates. Month and day names
+	// are always in English, so non-English layouts should be numeric.
+	DateFormat string
+
+	// JustNow, Ago, and In describe relative times. Ago and In are format
+	// strings taking a count and a unit.
+	JustNow string
+	Ago     string
+	In      string
+	// Units maps each unit of relative time, in English, to its singular and
+	// plural forms as used with Ago and In.
+	Units map[string][2]string
+
+	// Messages maps each English UI message to its translation.
+	Messages map[string]string
+}
+
+var locales = map[string]*locale{
+	"en": {
+		Tag:        "en",
+		DateFormat: "Jan 2, 2006 3:04 PM MST",
+		JustNow:    "just now",
+		Ago:        "%d %s ago",
+		In:         "in %d %s",
+		Units: map[string][2]string{
+			"minute": {"minute", "minutes"},
+			"hour":   {"hour", "hours"},
+			"day":    {"day", "days"},
+			"month":  {"month", "months"},
+			"year":   {"year", "years"},
+		},
+	},
+	"de": {
+		Tag:        "de",
+		DateFormat: "02.01.2006, 15:04 MST",
+		JustNow:    "gerade eben",
+		Ago:        "vor %d %s",
+		In:         "in %d %s",
+		Units: map[string][2]string{
+			"minute": {"Minute", "Minuten"},
+			"hour":   {"Stunde", "Stunden"},
+			"day":    {"Tag", "Tagen"},
+			"month":  {"Monat", "Monaten"},
+			"year":   {"Jahr", "Jahren"},
+		},
+		Messages: map[string]string{
+			"Last changed":           "Zuletzt geändert",
+			"photo":                  "Foto",
+			"photos":                 "Fotos",
+			"from":                   "vom",
+			"to":                     "bis",
+			"newest":                 "neuestes",
+			"No new photos recently": "In letzter Zeit keine neuen Fotos",
+			"Photo from":             "Foto vom",
+			"Archive":                "Archiv",
+			"cached":                 "zwischengespeichert",
+			"new":                    "neu",
+			"Feed unreachable; photo may be out of date": "Feed nicht erreichbar; Foto möglicherweise veraltet",
+		},
+	},
+}
+
+// localeNames returns the available locale tags, sorted.
+func localeNames() []string {
+	var names []string
+	for name := range locales {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// lookupLocale returns the locale for tag, matching case-insensitively and
+// falling back from a regional tag such as de-AT to its language.
+func lookupLocale(tag string) (*locale, error) {
+	tag = strings.ToLower(tag)
+	if l, ok := locales[tag]; ok {
+		return l, nil
+	}
+	if lang, _, ok := strings.Cut(tag, "-"); ok {
+		if l, ok := locales[lang]; ok {
+			return l, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown language %q (available: %s)", tag, strings.Join(localeNames(), ", "))
+}
+
+// tr translates an English UI message, returning it unchanged if l has no
+// translation.
+func (l *locale) tr(msg string) string {
+	if s, ok := l.Messages[msg]; ok {
+		return s
+	}
+	return msg
+}
+
+// formatTime formats t with l's date layout.
+func (l *locale) formatTime(t time.Time) string {
+	return t.Format(l.DateFormat)
+}
+
+// relativeTime describes t relative to now, e.g. "3 hours ago" or "in 5
+// minutes". It returns "" for the zero time.
+func (l *locale) relativeTime(t, now time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	d := now.Sub(t)
+	future := d < 0
+	if future {
+		d = -d
+	}
+
+	var n int
+	var unit string
+	switch {
+	case d < time.Minute:
+		return l.JustNow
+	case d < time.Hour:
+		n, unit = int(d/time.Minute), "minute"
+	case d < 24*time.Hour:
+		n, unit = int(d/time.Hour), "hour"
+	case d < 30*24*time.Hour:
+		n, unit = int(d/(24*time.Hour)), "day"
+	case d < 365*24*time.Hour:
+		n, unit = int(d/(30*24*time.Hour)), "month"
+	default:
+		n, unit = int(d/(365*24*time.Hour)), "year"
+	}
+	forms := l.Units[unit]
+	name := forms[0]
+	if n != 1 {
+		name = forms[1]
+	}
+
+	if future {
+		return fmt.Sprintf(l.In, n, name)
+	}
+	return fmt.Sprintf(l.Ago, n, name)
+}
@@ -1,260 +1,912 @@
 package main
 
 import (
-	"encoding/xml"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"flag"
 	"fmt"
-	"html/template"
 	"io"
+	"log/slog"
+	"maps"
 	"net/http"
+	"net/url"
 	"os"
-	"sort"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
 	"time"
+
+	"lakeview/feed"
 )
 
-type RSS struct {
-	Channel Channel `xml:"channel"`
-}
+// version is set at build time with -ldflags "-X main.version=...".
+var version = "dev"
 
-type Channel struct {
-	Items []Item `xml:"item"`
-}
+var defaultUserAgent = "lakeview/" + version + " (+https://github.com/cdzombak/lakeview)"
 
-type Item struct {
-	Description  string         `xml:"description"`
-	PubDate      string         `xml:"pubDate"`
-	Link         string         `xml:"link"`
-	MediaContent []MediaContent `xml:"http://search.yahoo.com/mrss/ content"`
-}
+// feedsEnvVar names the environment variable that supplies feeds when no
+// feed flags are given.
+const feedsEnvVar = "LAKEVIEW_FEEDS"
 
-type MediaContent struct {
-	URL    string `xml:"url,attr"`
-	Type   string `xml:"type,attr"`
-	Medium string `xml:"medium,attr"`
+var defaultFeeds = []string{
+	"https://mastodon.social/@livelakehuron.rss",
+	"https://mastodon.social/@livelakemichigan.rss",
+	"https://mastodon.social/@livelakesuperior.rss",
+	"https://mastodon.social/@livelakeerie.rss",
+	"https://mastodon.social/@livelakeontario.rss",
 }
 
-type Photo struct {
-	URL     string
-	PubDate string
-	Link    string
-}
+var errNoPhotos = errors.New("no photos found")
+
+// exitPartialFailure is the exit status when output was generated but some
+// feeds failed. Other errors exit with status 1.
+const exitPartialFailure = 2
 
 func main() {
-	outputFile := flag.String("out", "index.html", "Output HTML file path")
+	outputFile := flag.String("out", "index.html", "Output file path, or - for stdout; with several formats, one path per format or a single path whose extension is replaced for each")
+	archiveDir := flag.String("archive-dir", "", "Write a timestamped copy of the HTML gallery into this directory and update its archive.html index, instead of -out")
+	outputDir := flag.String("output-dir", "", "Write index.html, photos.json, and manifest.json into this directory instead of -out")
+	s3Target := flag.String("s3", os.Getenv(s3EnvVar), "Upload the output to this s3://BUCKET[/PREFIX] instead of writing files, each output named by the base name of its path (defaults to $"+s3EnvVar+"; credentials come from the standard AWS configuration)")
+	s3Region := flag.String("s3-region", "", "AWS region of the -s3 bucket (defaults to the region in the AWS configuration, e.g. $AWS_REGION)")
+	s3Endpoint := flag.String("s3-endpoint", "", "URL of an S3-compatible object store to upload to instead of AWS, e.g. https://minio.example:9000")
+	manifestFile := flag.String("manifest", "", "Also write a versioned manifest.json describing the feeds and photos for other programs to this path (defaults to manifest.json in -output-dir)")
+	format := flag.String("format", "html", "Output format: html, json, or rss, or a comma-separated list of them")
+	title := flag.String("title", "Great Lakes Live Photos", "Title of the gallery page and RSS feed")
+	lang := flag.String("lang", "en", "Language of the gallery page: "+strings.Join(localeNames(), ", "))
+	description := flag.String("description", "Recent photos from the Great Lakes live cameras", "Description of the gallery, used in link previews and the RSS feed")
+	refresh := flag.Duration("refresh", 30*time.Minute, "How often the page reloads itself in the browser (0 to disable)")
+	refreshJitter := flag.Duration("refresh-jitter", time.Minute, "Randomly reload the page up to this much earlier or later than -refresh, so that many displays don't reload at once (0 to disable; needs JavaScript; defaults to a quarter of a -refresh of 1m or less)")
+	siteURL := flag.String("site-url", "", "Public URL of the gallery, used as the RSS channel link")
+	theme := flag.String("theme", "card", "Look of the gallery page: "+strings.Join(themeNames(), ", "))
+	faviconFile := flag.String("favicon", "", "Image to use as the page's icon, inlined into the page (defaults to a built-in icon)")
+	templateFile := flag.String("template", "", "Custom HTML template file (defaults to the built-in template)")
+	feedsList := flag.String("feeds", "", "Comma-separated list of feed URLs")
+	feedsURL := flag.String("feeds-url", "", "URL of an OPML file, or a plain-text list in the -feeds-file format, whose feeds are fetched at startup and added to the other feeds")
+	feedsFile := flag.String("feeds-file", "", "File with one feed URL per line, optionally followed by \"| name | color\" (blank lines and # comments ignored)")
+	fromStdin := flag.Bool("stdin", false, "Read a single RSS or Atom feed from stdin instead of fetching feeds")
+	offline := flag.Bool("offline", false, "Read feeds from files in -fixtures-dir instead of the network, for reproducible development and testing")
+	fixturesDir := flag.String("fixtures-dir", "", "With -offline, the directory of saved feed files (see below for how they're named)")
+	hostInterval := flag.Duration("host-interval", time.Second, "Minimum time between requests for feeds on the same host (0 for no limit)")
+	concurrency := flag.Int("concurrency", 4, "Maximum number of feeds to fetch simultaneously")
+	timeout := flag.Duration("timeout", 30*time.Second, "Timeout for each feed request, including reading the body")
+	retries := flag.Int("retries", 3, "Number of times to retry a feed after a network error or 5xx response")
+	retryDelay := flag.Duration("retry-delay", time.Second, "Base delay before the first retry; doubles on each subsequent retry")
+	proxy := flag.String("proxy", "", "Proxy URL for all requests (defaults to the HTTP_PROXY, HTTPS_PROXY, and NO_PROXY environment variables)")
+	caCert := flag.String("ca-cert", "", "PEM file of additional CA certificates to trust for HTTPS requests, e.g. for a private instance")
+	insecure := flag.Bool("insecure", false, "DANGEROUS: don't verify HTTPS certificates at all; for testing only")
+	maxRedirects := flag.Int("max-redirects", 5, "Maximum number of redirects to follow for each request")
+	maxBody := flag.Int64("max-body", 5<<20, "Maximum size of a feed response in bytes (0 for no limit)")
+	limit := flag.Int("limit", 0, "Maximum number of photos to include, newest first (0 for no limit)")
+	maxPerFeed := flag.Int("max-per-feed", 0, "Maximum number of photos to keep from each feed, newest first (0 for no limit)")
+	sortOrder := flag.String("sort", "newest", "Order of the photos in every output format: newest, oldest, or random")
+	shuffle := flag.Bool("shuffle", false, "Same as -sort=random")
+	seed := flag.Uint64("seed", 0, "With -sort=random, the random seed; the same seed and photos give the same order (0 for a time-based seed)")
+	dedupe := flag.Bool("dedupe", true, "Collapse duplicate photos, as identified by -dedupe-by")
+	dedupeBy := flag.String("dedupe-by", "url", "What identifies duplicate photos: url (the image URL) or link (the post link)")
+	postImages := flag.String("post-images", "all", "With -dedupe-by=link, keep the first image of each post or all of them: first or all")
+	var includeFlags, excludeFlags []string
+	flag.Func("include", "Only include photos whose post title or description matches this regular expression (repeatable; every pattern must match)", func(s string) error {
+		includeFlags = append(includeFlags, s)
+		return nil
+	})
+	flag.Func("exclude", "Drop photos whose post title or description matches this regular expression (repeatable)", func(s string) error {
+		excludeFlags = append(excludeFlags, s)
+		return nil
+	})
+	caseSensitive := flag.Bool("case-sensitive", false, "Match -include and -exclude patterns case-sensitively")
+	preferFormat := flag.String("prefer-format", "avif,webp", "When a post offers an image in several formats, use the first of these formats it has, as a comma-separated list of MIME types or subtypes (empty for no preference)")
+	minWidth := flag.Int("min-width", 0, "Drop photos narrower than this many pixels, according to the feed (0 for no limit)")
+	minHeight := flag.Int("min-height", 0, "Drop photos shorter than this many pixels, according to the feed (0 for no limit)")
+	dropUnknownSize := flag.Bool("drop-unknown-size", false, "With -min-width or -min-height, also drop photos whose feed doesn't give their size")
+	since := flag.Duration("since", 0, "Only include photos published within this duration, e.g. 48h (0 for no limit)")
+	keepUndated := flag.Bool("keep-undated", false, "With -since, keep photos whose pubDate can't be parsed")
+	configFile := flag.String("config", "", "YAML config file; flags given on the command line override its values")
+	logLevel := flag.String("log-level", "info", "Log level: debug, info, warn, or error")
+	quiet := flag.Bool("quiet", false, "Only log warnings and errors, regardless of -log-level")
+	logFormat := flag.String("log-format", "text", "Log format: text or json")
+	serveMode := flag.Bool("serve", false, "Serve the HTML gallery over HTTP instead of writing a file; viewers can adjust the layout with ?columns=N&gap=PX")
+	addr := flag.String("addr", ":8080", "Address to listen on with -serve")
+	interval := flag.Duration("interval", 30*time.Minute, "How often to regenerate the gallery with -serve or -watch")
+	watch := flag.Bool("watch", false, "Keep running, fetching feeds every -interval and rewriting the output only when the photos change (use -cache-dir to make conditional requests)")
+	strict := flag.Bool("strict", false, "Treat any feed failure as fatal")
+	cacheDir := flag.String("cache-dir", "", "Directory for caching feed responses between runs (disabled if empty)")
+	highlightNewFor := flag.Duration("highlight-new", 0, "With -cache-dir, highlight photos that weren't in the gallery on the previous run, for this long after they first appear (0 to disable)")
+	cacheMaxAge := flag.Duration("cache-max-age", 24*time.Hour, "With -cache-dir, show a failed feed's cached photos if they were fetched within this long (0 to drop the feed's photos instead)")
+	excludeOnError := flag.Int("exclude-feed-on-error", 0, "With -cache-dir, skip a feed after this many consecutive failed runs until -exclude-cooldown has passed (0 to never skip)")
+	excludeCooldown := flag.Duration("exclude-cooldown", 6*time.Hour, "How long to skip a feed disabled by -exclude-feed-on-error before trying it again")
+	userAgent := flag.String("user-agent", defaultUserAgent, "User-Agent header sent with feed requests")
+	downloadDir := flag.String("download-dir", "", "Download images into this directory and reference the local copies")
+	downloadImages := flag.Bool("download-images", false, "With -output-dir, download images into its images subdirectory")
+	inlineImages := flag.Bool("inline-images", false, "Embed images in the HTML page as data URIs, for a single file that works without the network (videos stay remote)")
+	inlineMaxImage := flag.Int64("inline-max-image", 2<<20, "With -inline-images, the largest image in bytes to embed; larger ones stay remote (0 for no limit)")
+	inlineMaxTotal := flag.Int64("inline-max-total", 25<<20, "With -inline-images, the most image data in bytes to embed in the page; images past it stay remote (0 for no limit)")
+	force := flag.Bool("force", false, "Rewrite output files even if their content hasn't changed")
+	check := flag.Bool("check", false, "Fetch each feed once and print a table of its HTTP status, item and image counts, and any error, instead of writing output; exits non-zero if any feed fails or has no images")
+	dryRun := flag.Bool("dry-run", false, "Fetch and process feeds, then print a summary instead of writing output")
+	columns := flag.Int("columns", 4, "Number of gallery columns on the widest screens; narrower screens scale down proportionally")
+	breakpoints := flag.String("breakpoints", "", "Gallery columns by screen width, as WIDTH:COLUMNS pairs for screens up to WIDTH pixels wide plus default:COLUMNS for wider ones; overrides -columns (default 480:1,768:2,1200:3,default:4, scaled by -columns)")
+	preload := flag.Int("preload", 3, "Ask browsers to load the N newest images right away instead of lazily (0 to disable)")
+	chunkSize := flag.Int("chunk-size", 0, "Render this many photos up front and add the rest in chunks as the viewer scrolls, for very large galleries (0 to render all at once)")
+	gap := flag.Int("gap", 15, "Space between photos in the gallery, in pixels")
+	staleAfter := flag.Duration("stale-after", 6*time.Hour, "Flag a lake as stale in the gallery if its newest photo is older than this (0 to disable)")
+	groupByLake := flag.Bool("group-by-lake", false, "Render each lake's photos in a separate section")
+	includeVideo := flag.Bool("include-video", false, "Include videos from feeds as well as images")
+	verifyImages := flag.Bool("verify-images", false, "Drop photos whose image URL doesn't respond with an image to a HEAD request")
+	page := flag.Int("page", 1, "With -format=json and -page-size, the 1-based page to write")
+	pageSize := flag.Int("page-size", 0, "With -format=json, write a paginated envelope with this many photos per page (0 to write all photos as a plain array)")
+	// -auth values are parsed after flag.Parse, since the flag package would
+	// echo an invalid value, and its secret, in the error message.
+	var authFlags []string
+	flag.Func("auth", "Credential for feeds whose URL starts with a prefix, as PREFIX=TOKEN for a bearer token or PREFIX=USER:PASSWORD for basic auth (repeatable)", func(s string) error {
+		authFlags = append(authFlags, s)
+		return nil
+	})
+	showVersion := flag.Bool("version", false, "Print version information and exit")
+	flag.Usage = usage
 	flag.Parse()
 
-	feeds := []string{
-		"https://mastodon.social/@livelakehuron.rss",
-		"https://mastodon.social/@livelakemichigan.rss",
-		"https://mastodon.social/@livelakesuperior.rss",
-		"https://mastodon.social/@livelakeerie.rss",
-		"https://mastodon.social/@livelakeontario.rss",
+	if *showVersion {
+		fmt.Printf("lakeview %s (%s)\n", version, runtime.Version())
+		return
 	}
 
-	var allPhotos []Photo
-	for _, feedURL := range feeds {
-		photos, err := fetchPhotos(feedURL)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error fetching %s: %v\n", feedURL, err)
-			continue
+	if err := setupLogger(*logLevel, *logFormat, *quiet); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	cfg := &Config{}
+	if *configFile != "" {
+		var err error
+		if cfg, err = loadConfig(*configFile); err != nil {
+			fatal("Error loading config", "error", err)
+		}
+		if err := cfg.applyTo(flag.CommandLine); err != nil {
+			fatal("Error loading config", "error", err)
 		}
-		allPhotos = append(allPhotos, photos...)
 	}
 
-	if len(allPhotos) == 0 {
-		fmt.Fprintf(os.Stderr, "No photos found\n")
-		os.Exit(1)
+	switch *dedupeBy {
+	case "url", "link":
+	default:
+		fatal("Unknown -dedupe-by (want url or link)", "dedupe-by", *dedupeBy)
+	}
+	switch *sortOrder {
+	case "newest", "oldest":
+	case "random":
+		*shuffle = true
+	default:
+		fatal("Unknown -sort (want newest, oldest, or random)", "sort", *sortOrder)
+	}
+	switch *postImages {
+	case "first", "all":
+	default:
+		fatal("Unknown -post-images (want first or all)", "post-images", *postImages)
 	}
 
-	sort.Slice(allPhotos, func(i, j int) bool {
-		ti, _ := time.Parse(time.RFC1123Z, allPhotos[i].PubDate)
-		tj, _ := time.Parse(time.RFC1123Z, allPhotos[j].PubDate)
-		return ti.After(tj)
-	})
+	loc, err := lookupLocale(*lang)
+	if err != nil {
+		fatal("Invalid -lang", "error", err)
+	}
 
-	if err := generateHTML(allPhotos, *outputFile); err != nil {
-		fmt.Fprintf(os.Stderr, "Error generating HTML: %v\n", err)
-		os.Exit(1)
+	themeCSS, err := loadTheme(*theme)
+	if err != nil {
+		fatal("Invalid -theme", "error", err)
 	}
 
-	fmt.Printf("Generated %s successfully with %d photos\n", *outputFile, len(allPhotos))
-}
+	if *columns < 1 {
+		fatal("-columns must be at least 1", "columns", *columns)
+	}
+	if *preload < 0 {
+		fatal("-preload must not be negative", "preload", *preload)
+	}
+	layout := newColumnLayout(*columns)
+	if *breakpoints != "" {
+		if layout, err = parseBreakpoints(*breakpoints); err != nil {
+			fatal("Invalid -breakpoints", "error", err)
+		}
+	}
+
+	jitterSet := false
+	flag.Visit(func(f *flag.Flag) { jitterSet = jitterSet || f.Name == "refresh-jitter" })
+	if !jitterSet && *refresh > 0 && *refreshJitter >= *refresh {
+		// The default is meant for the default -refresh; keep it in
+		// proportion to shorter ones.
+		*refreshJitter = *refresh / 4
+	}
+	if *refreshJitter < 0 || (*refresh > 0 && *refreshJitter >= *refresh) {
+		fatal("-refresh-jitter must be at least 0 and less than -refresh", "refreshJitter", *refreshJitter, "refresh", *refresh)
+	}
+
+	if *gap < 0 {
+		fatal("-gap must not be negative", "gap", *gap)
+	}
+
+	if *archiveDir != "" && (*format != "html" || *outputDir != "") {
+		fatal("-archive-dir only supports HTML output and can't be combined with -output-dir")
+	}
+
+	if *offline {
+		if *fixturesDir == "" {
+			fatal("-offline requires -fixtures-dir")
+		}
+		if *verifyImages || *downloadDir != "" || *downloadImages || *inlineImages {
+			fatal("-offline can't be combined with -verify-images, -download-dir, -download-images, or -inline-images, which make network requests")
+		}
+	}
+
+	if *outputDir != "" {
+		if *s3Target == "" {
+			if err := os.MkdirAll(*outputDir, 0o755); err != nil {
+				fatal("Error creating output directory", "error", err)
+			}
+		}
+		*outputFile = filepath.Join(*outputDir, "index.html")
+		if *manifestFile == "" {
+			*manifestFile = filepath.Join(*outputDir, "manifest.json")
+		}
+		if *downloadImages {
+			*downloadDir = filepath.Join(*outputDir, "images")
+		}
+	}
+
+	postFilter, err := newTextFilter(includeFlags, excludeFlags, *caseSensitive)
+	if err != nil {
+		fatal("Invalid filter", "error", err)
+	}
+
+	targets, err := parseOutputs(*format, *outputFile)
+	if err != nil {
+		fatal("Invalid output options", "error", err)
+	}
+
+	if *inlineImages && *downloadDir != "" {
+		fatal("-inline-images can't be combined with -download-dir or -download-images")
+	}
+	if *inlineImages && *preload > 0 {
+		// A preload hint would repeat each embedded image in the page.
+		slog.Debug("Not preloading images embedded with -inline-images")
+		*preload = 0
+	}
 
-func fetchPhotos(url string) ([]Photo, error) {
-	resp, err := http.Get(url)
+	if *s3Target != "" {
+		if *archiveDir != "" || *downloadDir != "" {
+			fatal("-s3 can't be combined with -archive-dir, -download-dir, or -download-images")
+		}
+		for _, t := range targets {
+			if t.Path == stdoutPath {
+				fatal("-s3 can't upload output meant for stdout")
+			}
+		}
+	}
+
+	credentials := cfg.credentials()
+	for i, s := range authFlags {
+		c, err := parseCredential(s)
+		if err != nil {
+			fatal("Invalid -auth value", "index", i+1, "error", err)
+		}
+		credentials = append(credentials, c)
+	}
+
+	var proxyURL *url.URL
+	if *proxy != "" {
+		if proxyURL, err = url.Parse(*proxy); err != nil || proxyURL.Host == "" {
+			fatal("Invalid -proxy URL")
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxyFunc(proxyURL)
+	if *caCert != "" || *insecure {
+		if transport.TLSClientConfig, err = tlsConfig(*caCert, *insecure); err != nil {
+			fatal("Error configuring TLS", "error", err)
+		}
+	}
+
+	f := &feed.Fetcher{
+		Client: &http.Client{
+			Transport:     transport,
+			Timeout:       *timeout,
+			CheckRedirect: feed.CheckRedirect(*maxRedirects),
+		},
+		UserAgent:    *userAgent,
+		Retries:      *retries,
+		RetryDelay:   *retryDelay,
+		MaxBodySize:  *maxBody,
+		Credentials:  credentials,
+		IncludeVideo: *includeVideo,
+		ImageFormats: parseImageFormats(*preferFormat),
+		HostInterval: *hostInterval,
+	}
+	if *offline {
+		f.Getter = feed.FixtureDir(*fixturesDir)
+	}
+	if *cacheDir != "" {
+		if f.Cache, err = feed.NewCache(*cacheDir); err != nil {
+			fatal("Error opening cache", "error", err)
+		}
+		f.CacheMaxAge = *cacheMaxAge
+		f.DisableAfter = *excludeOnError
+		f.DisableCooldown = *excludeCooldown
+	}
+
+	var listed []string
+	var listedStyles map[string]feedStyle
+	if *feedsURL != "" {
+		if *offline {
+			fatal("-offline can't be combined with -feeds-url, which makes a network request")
+		}
+		if listed, listedStyles, err = fetchFeedsList(context.Background(), f, *feedsURL); err != nil {
+			fatal("Error fetching feeds list", "url", *feedsURL, "error", err)
+		}
+		slog.Info("Fetched feeds list", "url", *feedsURL, "feeds", len(listed))
+	}
+	feeds, styles, err := loadFeeds(*feedsList, *feedsFile, cfg, os.Getenv(feedsEnvVar), listed, listedStyles)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch RSS: %w", err)
+		fatal("Error loading feeds", "error", err)
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	favicon, touchIcon, err := loadIcons(*faviconFile)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		fatal("Error loading favicon", "error", err)
+	}
+	pageOpts := pageOptions{
+		Title:         *title,
+		Description:   *description,
+		SiteURL:       *siteURL,
+		GroupByLake:   *groupByLake,
+		Refresh:       *refresh,
+		RefreshJitter: *refreshJitter,
+		Columns:       layout,
+		Gap:           *gap,
+		ChunkSize:     *chunkSize,
+		Preload:       *preload,
+		StaleAfter:    *staleAfter,
+		Favicon:       favicon,
+		TouchIcon:     touchIcon,
+		Theme:         themeCSS,
+		Locale:        loc,
+	}
+
+	// ctx is cancelled on SIGINT or SIGTERM, which stops any fetches in
+	// progress.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *highlightNewFor > 0 && *cacheDir == "" {
+		fatal("-highlight-new requires -cache-dir to remember photos between runs")
+	}
+	if *watch && (*serveMode || *dryRun || *check || *fromStdin) {
+		fatal("-watch can't be combined with -serve, -dry-run, -check, or -stdin")
 	}
 
-	var rss RSS
-	if err := xml.Unmarshal(body, &rss); err != nil {
-		return nil, fmt.Errorf("failed to parse RSS: %w", err)
+	if *check {
+		if *fromStdin {
+			fatal("-check can't be combined with -stdin")
+		}
+		if !printHealth(os.Stdout, f.CheckAll(ctx, feeds, *concurrency)) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	fetch := func(ctx context.Context) ([]feed.Photo, feed.Summary) {
+		return f.FetchAll(ctx, feeds, *concurrency, *maxPerFeed)
+	}
+	if *fromStdin {
+		body, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fatal("Error reading feed from stdin", "error", err)
+		}
+		fetch = func(context.Context) ([]feed.Photo, feed.Summary) {
+			return parseStdin(f, body)
+		}
+	}
+
+	c := &collector{
+		fetcher:         f,
+		fetch:           fetch,
+		styles:          styles,
+		strict:          *strict,
+		filter:          postFilter,
+		dedupe:          *dedupe,
+		dedupeBy:        *dedupeBy,
+		allPostImages:   *postImages == "all",
+		minWidth:        *minWidth,
+		minHeight:       *minHeight,
+		dropUnknownSize: *dropUnknownSize,
+		since:           *since,
+		keepUndated:     *keepUndated,
+		verifyImages:    *verifyImages,
+		concurrency:     *concurrency,
+		limit:           *limit,
+		highlightNewFor: *highlightNewFor,
+		cacheDir:        *cacheDir,
+		dryRun:          *dryRun,
+		shuffle:         *shuffle,
+		seed:            *seed,
+		sortOrder:       *sortOrder,
 	}
 
-	var photos []Photo
+	if *serveMode {
+		if *interval <= 0 {
+			fatal("-interval must be positive", "interval", *interval)
+		}
 
-	for _, item := range rss.Channel.Items {
-		for _, media := range item.MediaContent {
-			if media.Medium == "image" {
-				photos = append(photos, Photo{
-					URL:     media.URL,
-					PubDate: item.PubDate,
-					Link:    item.Link,
-				})
+		t := loadTemplate(*templateFile, loc)
+		m := newMetrics()
+		render := func(photos []feed.Photo, generated time.Time, lq layoutQuery) ([]byte, error) {
+			var buf bytes.Buffer
+			if err := renderHTML(&buf, t, newPageData(photos, lq.apply(pageOpts), generated)); err != nil {
+				return nil, err
+			}
+			return buf.Bytes(), nil
+		}
+		generate := func(ctx context.Context) (galleryPage, error) {
+			start := time.Now()
+			photos, summary, err := c.collect(ctx)
+			m.observeFetch(summary)
+			if err != nil {
+				return galleryPage{}, err
+			}
+			generated := time.Now()
+			page, err := render(photos, generated, layoutQuery{})
+			if err != nil {
+				return galleryPage{}, err
 			}
+			m.observeSuccess(len(photos), start)
+			return galleryPage{page: page, photos: photos, generated: generated}, nil
+		}
+
+		if err := serve(ctx, *addr, *interval, generate, render); err != nil {
+			fatal("Error serving", "error", err)
+		}
+		return
+	}
+
+	var sink outputSink = fileSink{force: *force}
+	if *s3Target != "" {
+		uploader, err := newS3Sink(ctx, *s3Target, *s3Region, *s3Endpoint)
+		if err != nil {
+			fatal("Invalid -s3 target", "error", err)
+		}
+		sink = uploader
+	}
+
+	o := &outputWriter{
+		fetcher:        f,
+		sink:           sink,
+		concurrency:    *concurrency,
+		targets:        targets,
+		outputFile:     *outputFile,
+		outputDir:      *outputDir,
+		archiveDir:     *archiveDir,
+		manifestFile:   *manifestFile,
+		downloadDir:    *downloadDir,
+		inlineImages:   *inlineImages,
+		inlineMaxImage: *inlineMaxImage,
+		inlineMaxTotal: *inlineMaxTotal,
+		templateFile:   *templateFile,
+		loc:            loc,
+		pageOpts:       pageOpts,
+		page:           *page,
+		pageSize:       *pageSize,
+		title:          *title,
+		description:    *description,
+		siteURL:        *siteURL,
+	}
+
+	if *watch {
+		if *interval <= 0 {
+			fatal("-interval must be positive", "interval", *interval)
+		}
+		watchFeeds(ctx, *interval, c.collect, o.write)
+		return
+	}
+
+	allPhotos, summary, err := c.collect(ctx)
+	if err != nil {
+		fatal("Error collecting photos", "error", err)
+	}
+
+	if *dryRun {
+		printDryRun(os.Stdout, allPhotos, summary)
+		if summary.Failed > 0 {
+			logFailures(summary)
+			os.Exit(exitPartialFailure)
 		}
+		return
+	}
+
+	if err := o.write(ctx, allPhotos, summary); err != nil {
+		fatal("Error writing output", "error", err)
+	}
+
+	if summary.Failed > 0 {
+		logFailures(summary)
+		os.Exit(exitPartialFailure)
 	}
+}
 
-	return photos, nil
+// failureKind categorizes why a feed failed, for logFailures.
+func failureKind(err error) string {
+	var statusErr *feed.StatusError
+	var contentErr *feed.ContentError
+	var parseErr *feed.ParseError
+	var fetchErr *feed.FetchError
+	switch {
+	case errors.Is(err, feed.ErrFeedDisabled):
+		return "disabled"
+	case errors.As(err, &statusErr):
+		return "http"
+	case errors.As(err, &contentErr), errors.As(err, &parseErr):
+		return "parse"
+	case errors.As(err, &fetchErr):
+		return "network"
+	default:
+		return "other"
+	}
 }
 
-func generateHTML(photos []Photo, outputFile string) error {
-	tmpl := `<!DOCTYPE html>
-<html lang="en">
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <meta http-equiv="refresh" content="1800">
-    <title>Great Lakes Live Photos</title>
-    <style>
-        * {
-            margin: 0;
-            padding: 0;
-            box-sizing: border-box;
-        }
-
-        body {
-            font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, "Helvetica Neue", Arial, sans-serif;
-            background: #f5f5f5;
-            padding: 20px;
-        }
-
-        .masonry {
-            position: relative;
-        }
-
-        .photo-item {
-            position: absolute;
-            width: calc(25% - 12px);
-            background: white;
-            border-radius: 8px;
-            overflow: hidden;
-            will-change: box-shadow;
-        }
-
-        @media (max-width: 1200px) {
-            .photo-item {
-                width: calc(33.333% - 10px);
-            }
-        }
-
-        @media (max-width: 768px) {
-            .photo-item {
-                width: calc(50% - 8px);
-            }
-        }
-
-        @media (max-width: 480px) {
-            .photo-item {
-                width: 100%;
-            }
-        }
-
-        .photo-item:hover {
-            box-shadow: 0 2px 12px rgba(0,0,0,0.2);
-        }
-
-        .photo-item img {
-            width: 100%;
-            display: block;
-        }
-
-        .photo-item a {
-            display: block;
-        }
-    </style>
-</head>
-<body>
-    <div class="masonry">
-        {{range .}}
-        <div class="photo-item">
-            <a href="{{.Link}}" target="_blank" rel="noopener noreferrer">
-                <img src="{{.URL}}" alt="Photo from {{.PubDate}}" loading="lazy">
-            </a>
-        </div>
-        {{end}}
-    </div>
-    <script>
-        function layoutMasonry() {
-            const container = document.querySelector('.masonry');
-            const items = Array.from(document.querySelectorAll('.photo-item'));
-            const gap = 15;
-
-            let columnCount = 4;
-            if (window.innerWidth <= 480) columnCount = 1;
-            else if (window.innerWidth <= 768) columnCount = 2;
-            else if (window.innerWidth <= 1200) columnCount = 3;
-
-            const columnHeights = new Array(columnCount).fill(0);
-            const columnPositions = [];
-            for (let i = 0; i < columnCount; i++) {
-                columnPositions.push(i * (100 / columnCount));
-            }
-
-            items.forEach((item, index) => {
-                if (index < items.length) {
-                    const img = item.querySelector('img');
-                    if (img.complete) {
-                        positionItem(item, img);
-                    } else {
-                        img.addEventListener('load', () => positionItem(item, img));
-                    }
-                }
-            });
-
-            function positionItem(item, img) {
-                const minColumnIndex = columnHeights.indexOf(Math.min(...columnHeights));
-                const itemHeight = img.naturalHeight * (item.offsetWidth / img.naturalWidth);
-
-                item.style.left = columnPositions[minColumnIndex] + '%';
-                item.style.top = columnHeights[minColumnIndex] + 'px';
-
-                columnHeights[minColumnIndex] += itemHeight + gap;
-            }
-
-            setTimeout(() => {
-                const maxHeight = Math.max(...columnHeights);
-                container.style.height = maxHeight + 'px';
-            }, 100);
-        }
-
-        window.addEventListener('load', layoutMasonry);
-        window.addEventListener('resize', layoutMasonry);
-    </script>
-</body>
-</html>
-`
-
-	t, err := template.New("page").Parse(tmpl)
+// logFailures logs how many feeds in summary failed in each way.
+func logFailures(summary feed.Summary) {
+	counts := make(map[string]int)
+	for _, fr := range summary.Feeds {
+		if fr.Err != nil {
+			counts[failureKind(fr.Err)]++
+		}
+	}
+
+	args := []any{"failed", summary.Failed}
+	for _, kind := range slices.Sorted(maps.Keys(counts)) {
+		args = append(args, kind, counts[kind])
+	}
+	slog.Warn("Some feeds failed", args...)
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage of %s:\n", os.Args[0])
+	flag.PrintDefaults()
+	fmt.Fprintf(out, "\nFeeds are taken from -feeds and -feeds-file if either is set; otherwise from\n")
+	fmt.Fprintf(out, "the -config file; otherwise from the %s environment variable (comma or\n", feedsEnvVar)
+	fmt.Fprintf(out, "newline separated). The feeds listed at -feeds-url are added to these. If there\n")
+	fmt.Fprintf(out, "are still none, the built-in Great Lakes feeds are used. With -stdin, a single\n")
+	fmt.Fprintf(out, "feed is read from stdin and no feeds are fetched.\n")
+	fmt.Fprintf(out, "\nWith -offline, each feed is read from a file in -fixtures-dir named after its\n")
+	fmt.Fprintf(out, "URL: the scheme is dropped, every character other than a letter, digit, dot,\n")
+	fmt.Fprintf(out, "or hyphen becomes an underscore, and .xml is appended. For example,\n")
+	fmt.Fprintf(out, "https://mastodon.social/@livelakeerie.rss is read from\n")
+	fmt.Fprintf(out, "mastodon.social__livelakeerie.rss.xml. See testdata/fixtures in the source\n")
+	fmt.Fprintf(out, "repository for an example.\n")
+	fmt.Fprintf(out, "\nPhotos are shown newest first. -limit always keeps the newest photos; with\n")
+	fmt.Fprintf(out, "-sort=oldest they're then shown oldest first, and with -sort=random in random\n")
+	fmt.Fprintf(out, "order. Pass -seed to get the same random order each run.\n")
+	fmt.Fprintf(out, "\nDuplicates are found by image URL by default. This misses copies of an image\n")
+	fmt.Fprintf(out, "served under different, cache-busted URLs; -dedupe-by=link catches those by\n")
+	fmt.Fprintf(out, "comparing post links instead, but then relies on each feed linking to the\n")
+	fmt.Fprintf(out, "same canonical post and matches a post's images by their order in the post.\n")
+}
+
+// stdinFeedURL labels the feed read with -stdin in summaries and, when the
+// feed has no title, in photo captions.
+const stdinFeedURL = "stdin"
+
+// parseImageFormats parses -prefer-format into MIME types, so "webp" becomes
+// "image/webp". It never returns nil, since that means the default order.
+func parseImageFormats(s string) []string {
+	formats := []string{}
+	for _, f := range strings.Split(s, ",") {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if f == "" {
+			continue
+		}
+		if !strings.Contains(f, "/") {
+			f = "image/" + f
+		}
+		formats = append(formats, f)
+	}
+	return formats
+}
+
+// parseStdin parses a feed read from stdin, reporting the outcome in the same
+// form as Fetcher.FetchAll.
+func parseStdin(f *feed.Fetcher, body []byte) ([]feed.Photo, feed.Summary) {
+	photos, err := f.Parse(body, stdinFeedURL)
 	if err != nil {
-		return fmt.Errorf("failed to parse template: %w", err)
+		slog.Warn("Error parsing feed", "feed", stdinFeedURL, "error", err)
+		return nil, feed.Summary{Failed: 1, Feeds: []feed.Result{{URL: stdinFeedURL, Err: err}}}
+	}
+	return photos, feed.Summary{Succeeded: 1, Feeds: []feed.Result{{URL: stdinFeedURL, Photos: len(photos)}}}
+}
+
+// proxyFunc returns an http.Transport Proxy function that uses proxyURL, or
+// the proxy environment variables if proxyURL is nil. The proxy chosen for
+// each request is logged at debug level, without any credentials.
+func proxyFunc(proxyURL *url.URL) func(*http.Request) (*url.URL, error) {
+	proxy := http.ProxyFromEnvironment
+	if proxyURL != nil {
+		proxy = http.ProxyURL(proxyURL)
+	}
+	return func(req *http.Request) (*url.URL, error) {
+		u, err := proxy(req)
+		if err != nil || u == nil {
+			slog.Debug("Not using a proxy", "url", req.URL)
+			return u, err
+		}
+		slog.Debug("Using proxy", "url", req.URL, "proxy", u.Redacted())
+		return u, nil
+	}
+}
+
+// tlsConfig returns a TLS config that trusts the system's CAs plus those in
+// the PEM file at caFile, if set. If insecure is set, certificates aren't
+// verified at all.
+func tlsConfig(caFile string, insecure bool) (*tls.Config, error) {
+	cfg := &tls.Config{}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificates: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if insecure {
+		slog.Warn("HTTPS certificate verification is disabled by -insecure; connections can be intercepted")
+		cfg.InsecureSkipVerify = true
+	}
+	return cfg, nil
+}
+
+// printDryRun writes a human-readable summary of what would be generated.
+func printDryRun(w io.Writer, photos []feed.Photo, summary feed.Summary) {
+	fmt.Fprintf(w, "Total photos: %d\n", len(photos))
+
+	var oldest, newest time.Time
+	undated := 0
+	for _, p := range photos {
+		if p.Published.IsZero() {
+			undated++
+			continue
+		}
+		if oldest.IsZero() || p.Published.Before(oldest) {
+			oldest = p.Published
+		}
+		if p.Published.After(newest) {
+			newest = p.Published
+		}
+	}
+	if !newest.IsZero() {
+		fmt.Fprintf(w, "Newest: %s\n", newest.Format(time.RFC3339))
+		fmt.Fprintf(w, "Oldest: %s\n", oldest.Format(time.RFC3339))
+	}
+	if undated > 0 {
+		fmt.Fprintf(w, "Photos with unparseable dates: %d\n", undated)
+	}
+
+	fmt.Fprintf(w, "\nFeeds (%d succeeded, %d failed):\n", summary.Succeeded, summary.Failed)
+	for _, fr := range summary.Feeds {
+		if fr.Cached {
+			fmt.Fprintf(w, "  %s: error: %v (using %d cached photos)\n", fr.URL, fr.Err, fr.Photos)
+			continue
+		}
+		if fr.Err != nil {
+			fmt.Fprintf(w, "  %s: error: %v\n", fr.URL, fr.Err)
+			continue
+		}
+		fmt.Fprintf(w, "  %s: %d photos\n", fr.URL, fr.Photos)
 	}
+}
+
+// printHealth writes a table of feed check results and reports whether every
+// feed is healthy.
+func printHealth(w io.Writer, results []feed.Health) bool {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FEED\tSTATUS\tITEMS\tIMAGES\tRESULT")
+	ok := true
+	for _, h := range results {
+		status := "-"
+		if h.Status != 0 {
+			status = strconv.Itoa(h.Status)
+		}
+		result := "ok"
+		switch {
+		case h.Err != nil:
+			result = "error: " + h.Err.Error()
+		case h.Images == 0:
+			result = "no images"
+		}
+		ok = ok && h.OK()
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\n", h.URL, status, h.Items, h.Images, result)
+	}
+	tw.Flush()
+	return ok
+}
+
+// setupLogger installs the default slog logger, writing to stderr at the
+// given level and format. If quiet is set, the level is raised to at least
+// warn.
+func setupLogger(level, format string, quiet bool) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid log level %q", level)
+	}
+	if quiet {
+		lvl = max(lvl, slog.LevelWarn)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var h slog.Handler
+	switch format {
+	case "text":
+		h = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		h = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("invalid log format %q (want text or json)", format)
+	}
+
+	slog.SetDefault(slog.New(h))
+	return nil
+}
+
+// fatal logs msg at error level and exits.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// feedStyle is the display name, badge color, and caption emoji configured
+// for a feed.
+type feedStyle struct {
+	Name  string
+	Color string
+	Emoji string
+}
+
+// cssColor matches the colors accepted in a feeds file: hex colors and named
+// colors.
+var cssColor = regexp.MustCompile(`^(#[0-9a-fA-F]{3,8}|[a-zA-Z]+)$`)
+
+// loadFeeds returns the feed URLs given via -feeds and -feeds-file, along
+// with any styles set in the feeds file. If neither flag is set, it falls
+// back to the feeds in cfg, then to env (the value of feedsEnvVar). The
+// feeds in listed, from -feeds-url, are added to these, with their styles
+// used for feeds that weren't given one. If there are still no feeds, it
+// returns defaultFeeds.
+func loadFeeds(list, path string, cfg *Config, env string, listed []string, listedStyles map[string]feedStyle) ([]string, map[string]feedStyle, error) {
+	feeds := splitFeeds(list)
+
+	styles := make(map[string]feedStyle)
+	if path != "" {
+		fromFile, fileStyles, err := readFeedsFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		feeds = append(feeds, fromFile...)
+		styles = fileStyles
+	}
+
+	if len(feeds) == 0 && len(cfg.Feeds) > 0 {
+		feeds, styles = cfg.feedURLs(), cfg.styles()
+	}
+	if len(feeds) == 0 {
+		feeds = splitFeeds(env)
+	}
+
+	for _, u := range listed {
+		if !slices.Contains(feeds, u) {
+			feeds = append(feeds, u)
+		}
+		if _, ok := styles[u]; !ok && listedStyles[u] != (feedStyle{}) {
+			styles[u] = listedStyles[u]
+		}
+	}
+
+	if len(feeds) == 0 {
+		return defaultFeeds, nil, nil
+	}
+	return feeds, styles, nil
+}
+
+// parseCredential parses an -auth value. Errors don't include the value,
+// which contains a secret.
+func parseCredential(s string) (feed.Credential, error) {
+	prefix, secret, ok := strings.Cut(s, "=")
+	if !ok || prefix == "" || secret == "" {
+		return feed.Credential{}, errors.New("want PREFIX=TOKEN or PREFIX=USER:PASSWORD")
+	}
+	return newCredential(prefix, secret), nil
+}
+
+// newCredential returns a credential for URLs starting with prefix. secret is
+// USER:PASSWORD for basic auth, or otherwise a bearer token.
+func newCredential(prefix, secret string) feed.Credential {
+	if user, password, ok := strings.Cut(secret, ":"); ok {
+		return feed.Credential{Prefix: prefix, Username: user, Password: password}
+	}
+	return feed.Credential{Prefix: prefix, Token: secret}
+}
+
+// splitFeeds splits a comma- or newline-separated list of feed URLs.
+func splitFeeds(list string) []string {
+	var feeds []string
+	for _, u := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if u = strings.TrimSpace(u); u != "" {
+			feeds = append(feeds, u)
+		}
+	}
+	return feeds
+}
 
-	f, err := os.Create(outputFile)
+// readFeedsFile reads a feeds file, as described by parseFeedsList.
+func readFeedsFile(path string) ([]string, map[string]feedStyle, error) {
+	f, err := os.Open(path)
 	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
+		return nil, nil, fmt.Errorf("failed to open feeds file: %w", err)
 	}
 	defer f.Close()
+	return parseFeedsList(f)
+}
+
+// parseFeedsList parses a plain-text list of feeds. Each line holds a feed
+// URL, optionally followed by a display name and a badge color, separated by
+// "|":
+//
+//	https://mastodon.social/@livelakehuron.rss | Lake Huron | #1e88e5
+func parseFeedsList(r io.Reader) ([]string, map[string]feedStyle, error) {
+	var feeds []string
+	styles := make(map[string]feedStyle)
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "|")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		if len(fields) > 3 {
+			return nil, nil, fmt.Errorf("feeds file line %d: too many fields", n)
+		}
+		feedURL := fields[0]
+		feeds = append(feeds, feedURL)
 
-	if err := t.Execute(f, photos); err != nil {
-		return fmt.Errorf("failed to execute template: %w", err)
+		var style feedStyle
+		if len(fields) > 1 {
+			style.Name = fields[1]
+		}
+		if len(fields) > 2 {
+			if style.Color = fields[2]; style.Color != "" && !cssColor.MatchString(style.Color) {
+				return nil, nil, fmt.Errorf("feeds file line %d: invalid color %q", n, style.Color)
+			}
+		}
+		if style != (feedStyle{}) {
+			styles[feedURL] = style
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read feeds file: %w", err)
 	}
 
-	return nil
+	return feeds, styles, nil
 }
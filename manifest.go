@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"lakeview/feed"
+)
+
+// manifestSchemaVersion is the version of the manifest format. It is
+// incremented whenever a field is removed or its meaning changes; adding a
+// field doesn't change it.
+const manifestSchemaVersion = 1
+
+// manifest is the versioned description of a gallery written for other
+// programs by generateManifest. Unlike -format=json, its fields are a stable
+// contract.
+type manifest struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	Generated     string          `json:"generated"`
+	Title         string          `json:"title"`
+	Feeds         []manifestFeed  `json:"feeds"`
+	Photos        []manifestPhoto `json:"photos"`
+}
+
+type manifestFeed struct {
+	URL string `json:"url"`
+	// Title is the name shown for the feed's photos, or "" if the feed
+	// contributed none.
+	Title  string `json:"title"`
+	Photos int    `json:"photos"`
+	// Error is set if the feed couldn't be fetched.
+	Error string `json:"error,omitempty"`
+}
+
+type manifestPhoto struct {
+	MediaType string `json:"mediaType"`
+	URL       string `json:"url"`
+	ThumbURL  string `json:"thumbUrl"`
+	Link      string `json:"link"`
+	Source    string `json:"source"`
+	Feed      string `json:"feed"`
+	Width     int    `json:"width,omitempty"`
+	Height    int    `json:"height,omitempty"`
+	Alt       string `json:"alt,omitempty"`
+	// Published is the photo's date in RFC 3339 format, or "" if the feed's
+	// date couldn't be parsed.
+	Published string `json:"published,omitempty"`
+}
+
+// newManifest describes photos, and the feeds in summary, as of now.
+func newManifest(photos []feed.Photo, summary feed.Summary, title string, now time.Time) manifest {
+	m := manifest{
+		SchemaVersion: manifestSchemaVersion,
+		Generated:     now.UTC().Format(time.RFC3339),
+		Title:         title,
+		Feeds:         []manifestFeed{},
+		Photos:        make([]manifestPhoto, len(photos)),
+	}
+
+	titles := make(map[string]string)
+	counts := make(map[string]int)
+	for i, p := range photos {
+		if _, ok := titles[p.FeedURL]; !ok {
+			titles[p.FeedURL] = p.Source
+		}
+		counts[p.FeedURL]++
+
+		mp := manifestPhoto{
+			MediaType: p.MediaType,
+			URL:       p.URL,
+			ThumbURL:  p.ThumbURL,
+			Link:      p.Link,
+			Source:    p.Source,
+			Feed:      p.FeedURL,
+			Width:     p.Width,
+			Height:    p.Height,
+			Alt:       p.Alt,
+		}
+		if !p.Published.IsZero() {
+			mp.Published = p.Published.UTC().Format(time.RFC3339)
+		}
+		m.Photos[i] = mp
+	}
+
+	for _, fr := range summary.Feeds {
+		mf := manifestFeed{URL: fr.URL, Title: titles[fr.URL], Photos: counts[fr.URL]}
+		if fr.Err != nil {
+			mf.Error = fr.Err.Error()
+		}
+		m.Feeds = append(m.Feeds, mf)
+	}
+	return m
+}
+
+// generateManifest writes the manifest for photos and the feeds in summary.
+func generateManifest(ctx context.Context, sink outputSink, photos []feed.Photo, summary feed.Summary, title, outputFile string) error {
+	m := newManifest(photos, summary, title, time.Now())
+	return writeOutput(ctx, sink, outputFile, "application/json", func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(m); err != nil {
+			return fmt.Errorf("failed to encode manifest: %w", err)
+		}
+		return nil
+	})
+}
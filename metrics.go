@@ -0,0 +1,59 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"lakeview/feed"
+)
+
+// metrics reports the health of gallery generation in serve mode.
+type metrics struct {
+	feedsFetched       prometheus.Counter
+	feedFailures       prometheus.Counter
+	photos             prometheus.Gauge
+	lastSuccess        prometheus.Gauge
+	generationDuration prometheus.Gauge
+}
+
+// newMetrics creates the serve-mode metrics and registers them with the
+// default Prometheus registry.
+func newMetrics() *metrics {
+	m := &metrics{
+		feedsFetched: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "lakeview_feeds_fetched_total",
+			Help: "Number of feeds fetched successfully.",
+		}),
+		feedFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "lakeview_feed_failures_total",
+			Help: "Number of feed fetches that failed.",
+		}),
+		photos: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "lakeview_photos",
+			Help: "Number of photos in the most recently generated gallery.",
+		}),
+		lastSuccess: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "lakeview_last_success_timestamp_seconds",
+			Help: "Unix time of the last successful gallery generation.",
+		}),
+		generationDuration: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "lakeview_last_generation_duration_seconds",
+			Help: "Duration of the last successful gallery generation.",
+		}),
+	}
+
+	prometheus.MustRegister(m.feedsFetched, m.feedFailures, m.photos, m.lastSuccess, m.generationDuration)
+	return m
+}
+
+func (m *metrics) observeFetch(summary feed.Summary) {
+	m.feedsFetched.Add(float64(summary.Succeeded))
+	m.feedFailures.Add(float64(summary.Failed))
+}
+
+func (m *metrics) observeSuccess(photos int, start time.Time) {
+	m.photos.Set(float64(photos))
+	m.lastSuccess.SetToCurrentTime()
+	m.generationDuration.Set(time.Since(start).Seconds())
+}
@@ -0,0 +1,783 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"log/slog"
+	"math"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"lakeview/feed"
+)
+
+//go:embed template.html
+var defaultTemplate string
+
+//go:embed favicon.svg
+var defaultFavicon []byte
+
+//go:embed apple-touch-icon.png
+var defaultTouchIcon []byte
+
+// pageOptions controls how the HTML page is rendered.
+type pageOptions struct {
+	Title       string
+	Description string
+	// SiteURL is the public URL of the gallery, if known. It is used as the
+	// page's canonical URL and to resolve local image paths for link previews.
+	SiteURL     string
+	GroupByLake bool
+	// Refresh is the browser auto-refresh interval; 0 disables it.
+	Refresh time.Duration
+	// RefreshJitter is how much earlier or later than Refresh the page may
+	// reload, chosen at random by each browser.
+	RefreshJitter time.Duration
+	// Columns is the number of columns at each breakpoint.
+	Columns columnLayout
+	// Gap is the space between photos, in pixels.
+	Gap int
+	// ChunkSize, if positive, is the number of photos rendered up front in
+	// each gallery section; the rest are added as the user scrolls.
+	ChunkSize int
+	// Preload is the number of the newest images that the browser is asked
+	// to load early rather than lazily.
+	Preload int
+	// StaleAfter is how old a source's newest photo can be before the
+	// source is flagged as stale; 0 disables the check.
+	StaleAfter time.Duration
+	// Favicon and TouchIcon are data URIs for the page's icons.
+	Favicon   template.URL
+	TouchIcon template.URL
+	// Theme is the CSS of the page's theme, from loadTheme.
+	Theme template.CSS
+	// Locale is the language of the page.
+	Locale *locale
+}
+
+// pageData is the data passed to the HTML template.
+type pageData struct {
+	Lang        string
+	Title       string
+	Description string
+	SiteURL     string
+	// Image is the absolute URL of the newest photo, for link previews.
+	Image          string
+	RefreshSeconds int
+	// RefreshJitterSeconds is 0 if the refresh isn't jittered.
+	RefreshJitterSeconds int
+	Columns              columnLayout
+	Gap                  int
+	ChunkSize            int
+	Favicon              template.URL
+	TouchIcon            template.URL
+	Theme                template.CSS
+	Photos               []feed.Photo
+	// Preload holds the photos to emit preload hints for.
+	Preload []feed.Photo
+	// Generated is when the page was rendered. It's shown as when the page
+	// last changed, since fileSink keeps a page whose only changes are times.
+	Generated time.Time
+	Stats     pageStats
+	// Groups holds the photos split up by source when grouping by lake.
+	Groups []photoGroup
+}
+
+// columnLayout is the number of masonry columns at each breakpoint: Max
+// above the widest breakpoint, and each breakpoint's Columns up to its
+// MaxWidth. Breakpoints are ordered widest first, so that in both the CSS and
+// the script, later (narrower) breakpoints take precedence. The template
+// sizes each of n columns as (100% - (n-1)*gap) / n.
+type columnLayout struct {
+	Max         int
+	Breakpoints []breakpoint
+}
+
+type breakpoint struct {
+	MaxWidth int
+	Columns  int
+}
+
+// newColumnLayout scales the default 4/3/2/1 step-down at 1200, 768, and
+// 480 pixels to max columns at the widest breakpoint.
+func newColumnLayout(max int) columnLayout {
+	scale := func(n, d int) int {
+		return int(math.Max(1, math.Round(float64(max*n)/float64(d))))
+	}
+	return columnLayout{
+		Max: max,
+		Breakpoints: []breakpoint{
+			{MaxWidth: 1200, Columns: scale(3, 4)},
+			{MaxWidth: 768, Columns: scale(1, 2)},
+			{MaxWidth: 480, Columns: 1},
+		},
+	}
+}
+
+// imageSizes returns the sizes attribute for a gallery image's srcset: the
+// width of one of n columns, with gap pixels between them, at each
+// breakpoint. Breakpoints are listed narrowest first, since the browser uses
+// the first that matches.
+func (l columnLayout) imageSizes(gap int) string {
+	width := func(n int) string {
+		if n == 1 {
+			return "100vw"
+		}
+		return fmt.Sprintf("calc((100vw - %dpx) / %d)", (n-1)*gap, n)
+	}
+	var parts []string
+	for _, b := range slices.Backward(l.Breakpoints) {
+		parts = append(parts, fmt.Sprintf("(max-width: %dpx) %s", b.MaxWidth, width(b.Columns)))
+	}
+	return strings.Join(append(parts, width(l.Max)), ", ")
+}
+
+// parseBreakpoints parses a comma-separated list of WIDTH:COLUMNS pairs, each
+// giving the number of columns for screens up to WIDTH pixels wide, plus a
+// default:COLUMNS pair for wider screens, e.g.
+// "480:1,768:2,1200:3,default:4".
+func parseBreakpoints(s string) (columnLayout, error) {
+	var layout columnLayout
+	seen := make(map[int]bool)
+	for _, part := range strings.Split(s, ",") {
+		width, cols, ok := strings.Cut(strings.TrimSpace(part), ":")
+		if !ok {
+			return columnLayout{}, fmt.Errorf("breakpoint %q isn't WIDTH:COLUMNS", part)
+		}
+		n, err := strconv.Atoi(cols)
+		if err != nil || n < 1 {
+			return columnLayout{}, fmt.Errorf("breakpoint %q must have at least 1 column", part)
+		}
+		if width == "default" {
+			if layout.Max != 0 {
+				return columnLayout{}, errors.New("more than one default breakpoint")
+			}
+			layout.Max = n
+			continue
+		}
+		w, err := strconv.Atoi(width)
+		if err != nil || w < 1 {
+			return columnLayout{}, fmt.Errorf("breakpoint %q must have a positive width or \"default\"", part)
+		}
+		if seen[w] {
+			return columnLayout{}, fmt.Errorf("more than one breakpoint at %dpx", w)
+		}
+		seen[w] = true
+		layout.Breakpoints = append(layout.Breakpoints, breakpoint{MaxWidth: w, Columns: n})
+	}
+	if layout.Max == 0 {
+		return columnLayout{}, errors.New("missing default:COLUMNS breakpoint")
+	}
+
+	sort.Slice(layout.Breakpoints, func(i, j int) bool {
+		return layout.Breakpoints[i].MaxWidth > layout.Breakpoints[j].MaxWidth
+	})
+	return layout, nil
+}
+
+// pageStats summarizes the photos on the page for its footer.
+type pageStats struct {
+	Total int
+	// Oldest and Newest bound the photos' parsed dates; both are zero if no
+	// photo has one.
+	Oldest, Newest time.Time
+	// Sources counts the photos from each source, in order of first
+	// appearance.
+	Sources []sourceCount
+}
+
+type sourceCount struct {
+	Source string
+	Count  int
+	// Newest is the date of the source's newest photo, if any has one.
+	Newest time.Time
+	// Stale is set if Newest is older than pageOptions.StaleAfter.
+	Stale bool
+}
+
+func newPageStats(photos []feed.Photo, now time.Time, staleAfter time.Duration) pageStats {
+	stats := pageStats{Total: len(photos)}
+	index := make(map[string]int)
+	for _, p := range photos {
+		i, ok := index[p.Source]
+		if !ok {
+			i = len(stats.Sources)
+			index[p.Source] = i
+			stats.Sources = append(stats.Sources, sourceCount{Source: p.Source})
+		}
+		stats.Sources[i].Count++
+
+		if p.Published.IsZero() {
+			continue
+		}
+		if p.Published.After(stats.Sources[i].Newest) {
+			stats.Sources[i].Newest = p.Published
+		}
+		if stats.Oldest.IsZero() || p.Published.Before(stats.Oldest) {
+			stats.Oldest = p.Published
+		}
+		if p.Published.After(stats.Newest) {
+			stats.Newest = p.Published
+		}
+	}
+
+	if staleAfter > 0 {
+		for i, s := range stats.Sources {
+			stats.Sources[i].Stale = !s.Newest.IsZero() && now.Sub(s.Newest) > staleAfter
+		}
+	}
+	return stats
+}
+
+// photoGroup is the photos from one source, newest first.
+type photoGroup struct {
+	Source string
+	Photos []feed.Photo
+}
+
+// newPageData builds the template data for photos, which must already be
+// sorted, for a page generated at now. If opts.GroupByLake is set, photos are
+// also grouped by source, with the groups ordered by their first photo.
+func newPageData(photos []feed.Photo, opts pageOptions, now time.Time) pageData {
+	data := pageData{
+		Lang:           opts.Locale.Tag,
+		Title:          opts.Title,
+		Description:    opts.Description,
+		SiteURL:        opts.SiteURL,
+		RefreshSeconds: int(opts.Refresh.Seconds()),
+		Columns:        opts.Columns,
+		Gap:            opts.Gap,
+		ChunkSize:      opts.ChunkSize,
+		Favicon:        opts.Favicon,
+		TouchIcon:      opts.TouchIcon,
+		Theme:          opts.Theme,
+		Photos:         photos,
+		Generated:      now,
+		Stats:          newPageStats(photos, now, opts.StaleAfter),
+	}
+	if img := previewImage(photos); img != "" {
+		data.Image = previewImageURL(img, opts.SiteURL)
+	}
+	if opts.Refresh > 0 {
+		data.RefreshJitterSeconds = int(opts.RefreshJitter.Seconds())
+	}
+	data.Photos = setImageSizes(photos, opts.Columns.imageSizes(opts.Gap))
+	if opts.Preload > 0 {
+		data.Photos, data.Preload = markPreload(data.Photos, opts.Preload)
+		if len(data.Preload) < opts.Preload {
+			slog.Debug("Fewer images than -preload; preloading all of them", "preload", opts.Preload, "images", len(data.Preload))
+		}
+	}
+	if !opts.GroupByLake {
+		return data
+	}
+
+	// Group data.Photos rather than photos, so the groups keep the Preload
+	// marks.
+	index := make(map[string]int)
+	for _, p := range data.Photos {
+		i, ok := index[p.Source]
+		if !ok {
+			i = len(data.Groups)
+			index[p.Source] = i
+			data.Groups = append(data.Groups, photoGroup{Source: p.Source})
+		}
+		data.Groups[i].Photos = append(data.Groups[i].Photos, p)
+	}
+	return data
+}
+
+// setImageSizes returns a copy of photos with ImageSizes set to sizes on the
+// images that have a srcset.
+func setImageSizes(photos []feed.Photo, sizes string) []feed.Photo {
+	photos = slices.Clone(photos)
+	for i, p := range photos {
+		if len(p.Sizes) > 0 {
+			photos[i].ImageSizes = sizes
+		}
+	}
+	return photos
+}
+
+// markPreload returns a copy of photos with Preload set on the n newest
+// images, and those images, newest first. Videos aren't preloaded.
+func markPreload(photos []feed.Photo, n int) ([]feed.Photo, []feed.Photo) {
+	var images []int
+	for i, p := range photos {
+		if p.MediaType == feed.MediaImage {
+			images = append(images, i)
+		}
+	}
+	sort.SliceStable(images, func(i, j int) bool {
+		return photos[images[i]].Published.After(photos[images[j]].Published)
+	})
+
+	marked := slices.Clone(photos)
+	var preload []feed.Photo
+	for _, i := range images[:min(n, len(images))] {
+		marked[i].Preload = true
+		preload = append(preload, marked[i])
+	}
+	return marked, preload
+}
+
+// loadIcons returns data URIs for the page's favicon and Apple touch icon.
+// If path is empty, the built-in icons are used; otherwise the image at path
+// is used for both.
+func loadIcons(path string) (favicon, touchIcon template.URL, err error) {
+	if path == "" {
+		return dataURI("image/svg+xml", defaultFavicon), dataURI("image/png", defaultTouchIcon), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read favicon: %w", err)
+	}
+	mimeType := mime.TypeByExtension(filepath.Ext(path))
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	icon := dataURI(mimeType, data)
+	return icon, icon, nil
+}
+
+// dataURI returns a base64 data URI holding data.
+func dataURI(mimeType string, data []byte) template.URL {
+	return template.URL("data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data))
+}
+
+// outputTarget is an output file and the format to write it in.
+type outputTarget struct {
+	Format string
+	Path   string
+}
+
+// outputExtensions are the file extensions used to name each format's output
+// when several formats share one path.
+var outputExtensions = map[string]string{
+	"html": ".html",
+	"json": ".json",
+	"rss":  ".xml",
+}
+
+// parseOutputs pairs each of the comma-separated formats with an output
+// path. outs is either a comma-separated list with one path per format, or a
+// single path; with several formats, each format's path is then derived from
+// it by replacing its extension. Two formats can't share a path.
+func parseOutputs(formats, outs string) ([]outputTarget, error) {
+	fs := strings.Split(formats, ",")
+	paths := strings.Split(outs, ",")
+	if len(paths) != 1 && len(paths) != len(fs) {
+		return nil, fmt.Errorf("got %d output paths for %d formats", len(paths), len(fs))
+	}
+	if len(fs) > 1 && outs == stdoutPath {
+		return nil, errors.New("only one format can be written to stdout")
+	}
+
+	var targets []outputTarget
+	seen := make(map[string]string)
+	for i, format := range fs {
+		format = strings.TrimSpace(format)
+		ext, ok := outputExtensions[format]
+		if !ok {
+			return nil, fmt.Errorf("unknown format %q (want html, json, or rss)", format)
+		}
+
+		path := strings.TrimSuffix(outs, filepath.Ext(outs)) + ext
+		if len(paths) == len(fs) {
+			path = strings.TrimSpace(paths[i])
+		}
+		if other, ok := seen[filepath.Clean(path)]; ok {
+			return nil, fmt.Errorf("%s and %s output would both be written to %s", other, format, path)
+		}
+		seen[filepath.Clean(path)] = format
+		targets = append(targets, outputTarget{Format: format, Path: path})
+	}
+	return targets, nil
+}
+
+// previewImage returns the image for link previews: the newest image, or the
+// poster of a video if it's newer, whatever order photos are sorted in. It
+// returns "" if no photo has an image.
+func previewImage(photos []feed.Photo) string {
+	var newest *feed.Photo
+	var image string
+	for i, p := range photos {
+		u := p.URL
+		if p.MediaType != feed.MediaImage {
+			u = p.ThumbURL
+		}
+		if u == "" {
+			continue
+		}
+		if newest == nil || p.Published.After(newest.Published) {
+			newest, image = &photos[i], u
+		}
+	}
+	return image
+}
+
+// previewImageURL resolves imageURL, which may be a local path when images
+// have been downloaded, against siteURL. Link previews need an absolute URL,
+// so a relative path is dropped if there's no site URL to resolve it against.
+func previewImageURL(imageURL, siteURL string) string {
+	u, err := url.Parse(imageURL)
+	if err != nil {
+		return ""
+	}
+	if u.IsAbs() {
+		return imageURL
+	}
+	base, err := url.Parse(siteURL)
+	if err != nil || !base.IsAbs() {
+		return ""
+	}
+	return base.ResolveReference(u).String()
+}
+
+// renderHTML renders the gallery page to w.
+func renderHTML(w io.Writer, t *template.Template, data pageData) error {
+	if err := t.Execute(w, data); err != nil {
+		return fmt.Errorf("failed to execute template: %w", err)
+	}
+	return nil
+}
+
+// generateHTML renders the gallery page to outputFile.
+func generateHTML(ctx context.Context, sink outputSink, t *template.Template, data pageData, outputFile string) error {
+	return writeOutput(ctx, sink, outputFile, "text/html; charset=utf-8", func(w io.Writer) error {
+		return renderHTML(w, t, data)
+	})
+}
+
+// outputSink stores rendered output: fileSink writes it to local files, and
+// s3Sink uploads it to an object store.
+type outputSink interface {
+	// put stores body, which has the given MIME type, as the output at path.
+	put(ctx context.Context, path, contentType string, body []byte) error
+}
+
+// writeOutput renders output with write and stores it in sink at path.
+// Nothing is stored if rendering fails.
+func writeOutput(ctx context.Context, sink outputSink, path, contentType string, write func(w io.Writer) error) error {
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return err
+	}
+	return sink.put(ctx, path, contentType, buf.Bytes())
+}
+
+// stdoutPath is the output path that means standard output.
+const stdoutPath = "-"
+
+// fileSink writes output to local files. Unless force is set, it compares
+// the output's hash with the existing file, which is only replaced if the
+// content changed, so that its modification time reflects real changes.
+// Times that change on every run, such as when the page was generated, are
+// left out of the comparison; see volatileOutput. A page that is kept
+// therefore shows the generation and relative times of the run that last
+// changed it, which is why the page labels its generation time "Last
+// changed". Stale lakes are flagged outside those times, so the flags are
+// always those of the latest run. The path stdoutPath means standard output.
+type fileSink struct {
+	force bool
+}
+
+func (s fileSink) put(_ context.Context, path, contentType string, body []byte) error {
+	if path == stdoutPath {
+		_, err := os.Stdout.Write(body)
+		return err
+	}
+
+	if !s.force {
+		if sum, err := fileHash(path, contentType); err == nil && sum == stableHash(contentType, body) {
+			slog.Info("Output unchanged; not rewriting", "path", path)
+			return nil
+		}
+	}
+
+	return writeFileAtomic(path, func(w io.Writer) error {
+		_, err := w.Write(body)
+		return err
+	})
+}
+
+// volatileOutput matches, for each content type, the parts of the output
+// that change from run to run even when the photos don't: the times on the
+// HTML page, which include relative times like "2 hours ago" and when the
+// page was generated, and the manifest's generation time.
+var volatileOutput = map[string]*regexp.Regexp{
+	"text/html; charset=utf-8": regexp.MustCompile(`(?s)<time\b[^>]*>.*?</time>`),
+	"application/json":         regexp.MustCompile(`"generated":\s*"[^"]*"`),
+}
+
+// stableHash returns the SHA-256 hash of body, output of the given content
+// type, without its volatileOutput.
+func stableHash(contentType string, body []byte) [sha256.Size]byte {
+	if re, ok := volatileOutput[contentType]; ok {
+		body = re.ReplaceAll(body, nil)
+	}
+	return sha256.Sum256(body)
+}
+
+// fileHash returns the stableHash of the file at path.
+func fileHash(path, contentType string) ([sha256.Size]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return stableHash(contentType, data), nil
+}
+
+// writeFileAtomic calls write with a temporary file in the same directory as
+// path, then renames it over path. Readers see either the old file or the
+// complete new one, never a partial write.
+func writeFileAtomic(path string, write func(w io.Writer) error) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	tmpName := f.Name()
+	defer os.Remove(tmpName)
+
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(0o644); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to set output file permissions: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		return fmt.Errorf("failed to replace output file: %w", err)
+	}
+
+	return nil
+}
+
+// loadTemplate parses the custom template at path, falling back to the
+// embedded default if path is empty or the custom template can't be used.
+// Times and messages in the template are formatted for l.
+func loadTemplate(path string, l *locale) *template.Template {
+	if path != "" {
+		t, err := template.New(filepath.Base(path)).Funcs(templateFuncs(l)).ParseFiles(path)
+		if err == nil {
+			return t
+		}
+		slog.Warn("Using default template", "error", err)
+	}
+	return template.Must(template.New("page").Funcs(templateFuncs(l)).Parse(defaultTemplate))
+}
+
+// timeNow returns the time that relative times on the page are measured
+// from. Tests replace it to render pages that don't change over time.
+var timeNow = time.Now
+
+// templateFuncs returns the functions available to templates, formatting
+// times and translating messages for l.
+func templateFuncs(l *locale) template.FuncMap {
+	return template.FuncMap{
+		"version":       func() string { return version },
+		"relTime":       func(t time.Time) string { return l.relativeTime(t, timeNow()) },
+		"absTime":       l.formatTime,
+		"isoTime":       func(t time.Time) string { return t.Format(time.RFC3339) },
+		"tr":            l.tr,
+		"srcset":        srcset,
+		"imageSrc":      imageSrc,
+		"preloadSrcset": preloadSrcset,
+		"chunks":        chunkPhotos,
+	}
+}
+
+// chunkPhotos splits photos into chunks of size photos, or returns them as a
+// single chunk if size isn't positive.
+func chunkPhotos(photos []feed.Photo, size int) [][]feed.Photo {
+	if size <= 0 || len(photos) <= size {
+		return [][]feed.Photo{photos}
+	}
+	var chunks [][]feed.Photo
+	for len(photos) > size {
+		chunks = append(chunks, photos[:size])
+		photos = photos[size:]
+	}
+	return append(chunks, photos)
+}
+
+// srcset formats sizes as the value of an img srcset attribute. Commas and
+// spaces in URLs are escaped since they delimit srcset candidates.
+func srcset(sizes []feed.MediaContent) string {
+	escaper := strings.NewReplacer(",", "%2C", " ", "%20")
+	parts := make([]string, len(sizes))
+	for i, s := range sizes {
+		parts[i] = fmt.Sprintf("%s %dw", escaper.Replace(s.URL), s.Width)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// imageSrc returns u for use as an img src. html/template rejects data URIs
+// there, so those of images embedded by inlinePhotos are marked as safe;
+// feeds' own URLs are limited to http and https by the feed package, so any
+// data URI came from inlinePhotos.
+func imageSrc(u string) any {
+	if strings.HasPrefix(u, "data:image/") {
+		return template.URL(u)
+	}
+	return u
+}
+
+// preloadSrcset formats p's sizes as the imagesrcset and imagesizes
+// attributes of a preload link, with the same sizes as the gallery's img
+// tags. They're rendered here because html/template treats imagesrcset as a
+// single URL and would escape the spaces between candidates.
+func preloadSrcset(p feed.Photo) template.HTMLAttr {
+	return template.HTMLAttr(fmt.Sprintf(`imagesrcset="%s" imagesizes="%s"`, html.EscapeString(srcset(p.Sizes)), html.EscapeString(p.ImageSizes)))
+}
+
+// jsonPage is the envelope written by generateJSON when paginating.
+type jsonPage struct {
+	Total     int          `json:"total"`
+	Page      int          `json:"page"`
+	PageSize  int          `json:"pageSize"`
+	PageCount int          `json:"pageCount"`
+	Items     []feed.Photo `json:"items"`
+}
+
+// paginate returns the given 1-based page of photos. Pages out of range
+// have no items.
+func paginate(photos []feed.Photo, page, pageSize int) jsonPage {
+	p := jsonPage{
+		Total:     len(photos),
+		Page:      page,
+		PageSize:  pageSize,
+		PageCount: (len(photos) + pageSize - 1) / pageSize,
+		Items:     []feed.Photo{},
+	}
+
+	start := (page - 1) * pageSize
+	if page >= 1 && start < len(photos) {
+		end := min(start+pageSize, len(photos))
+		p.Items = photos[start:end]
+	}
+	return p
+}
+
+// generateJSON writes photos as a JSON array, or, if pageSize is positive,
+// as a jsonPage envelope holding the given page.
+func generateJSON(ctx context.Context, sink outputSink, photos []feed.Photo, page, pageSize int, outputFile string) error {
+	var v any = photos
+	if pageSize > 0 {
+		v = paginate(photos, page, pageSize)
+	}
+
+	return writeOutput(ctx, sink, outputFile, "application/json", func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(v); err != nil {
+			return fmt.Errorf("failed to encode JSON: %w", err)
+		}
+		return nil
+	})
+}
+
+// rssOutput and its related types describe the RSS 2.0 feed written by
+// generateRSS. They are separate from RSS, which only models what we read.
+type rssOutput struct {
+	XMLName xml.Name      `xml:"rss"`
+	Version string        `xml:"version,attr"`
+	Channel rssOutChannel `xml:"channel"`
+}
+
+type rssOutChannel struct {
+	Title       string       `xml:"title"`
+	Link        string       `xml:"link,omitempty"`
+	Description string       `xml:"description"`
+	Items       []rssOutItem `xml:"item"`
+}
+
+type rssOutItem struct {
+	Title     string          `xml:"title"`
+	Link      string          `xml:"link"`
+	GUID      string          `xml:"guid"`
+	PubDate   string          `xml:"pubDate,omitempty"`
+	Enclosure rssOutEnclosure `xml:"enclosure"`
+}
+
+type rssOutEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int    `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+func generateRSS(ctx context.Context, sink outputSink, photos []feed.Photo, title, description, siteURL, outputFile string) error {
+	feed := rssOutput{
+		Version: "2.0",
+		Channel: rssOutChannel{
+			Title:       title,
+			Link:        siteURL,
+			Description: description,
+		},
+	}
+
+	for _, p := range photos {
+		pubDate := p.PubDate
+		if !p.Published.IsZero() {
+			pubDate = p.Published.Format(time.RFC1123Z)
+		}
+
+		feed.Channel.Items = append(feed.Channel.Items, rssOutItem{
+			Title:   p.Source,
+			Link:    p.Link,
+			GUID:    p.URL,
+			PubDate: pubDate,
+			Enclosure: rssOutEnclosure{
+				URL:  p.URL,
+				Type: imageType(p.URL),
+			},
+		})
+	}
+
+	return writeOutput(ctx, sink, outputFile, "application/rss+xml", func(w io.Writer) error {
+		if _, err := io.WriteString(w, xml.Header); err != nil {
+			return fmt.Errorf("failed to write RSS: %w", err)
+		}
+		enc := xml.NewEncoder(w)
+		enc.Indent("", "  ")
+		if err := enc.Encode(feed); err != nil {
+			return fmt.Errorf("failed to encode RSS: %w", err)
+		}
+		return nil
+	})
+}
+
+// imageType guesses an image's MIME type from its URL, defaulting to JPEG.
+func imageType(imageURL string) string {
+	if u, err := url.Parse(imageURL); err == nil {
+		if t := mime.TypeByExtension(path.Ext(u.Path)); t != "" {
+			return t
+		}
+	}
+	return "image/jpeg"
+}
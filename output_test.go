@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"lakeview/feed"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// goldenNow is the time the golden pages are generated at.
+var goldenNow = time.Date(2026, 10, 12, 14, 0, 0, 0, time.UTC)
+
+// goldenPhotos returns photos covering the page's variations: several
+// sources, captions and colors, srcsets, authors, a video, and cached, new,
+// and undated photos. They're sorted newest first, as newPageData expects.
+func goldenPhotos() []feed.Photo {
+	at := func(hours int) time.Time { return goldenNow.Add(-time.Duration(hours) * time.Hour) }
+	return []feed.Photo{
+		{
+			MediaType: feed.MediaImage,
+			URL:       "https://example.com/media/huron-3.jpg",
+			ThumbURL:  "https://example.com/media/huron-3-thumb.jpg",
+			PubDate:   "Mon, 12 Oct 2026 13:00:00 +0000",
+			Published: at(1),
+			Link:      "https://example.com/huron/3",
+			Source:    "Lake Huron",
+			Caption:   "🌊 Lake Huron",
+			Color:     "#1e88e5",
+			Alt:       "Waves breaking on the Lake Huron shore",
+			Width:     1280,
+			Height:    960,
+			Author:    "@livelakehuron@mastodon.social",
+			AuthorURL: "https://mastodon.social/@livelakehuron",
+			Sizes: []feed.MediaContent{
+				{URL: "https://example.com/media/huron-3-640.jpg", Width: 640},
+				{URL: "https://example.com/media/huron-3.jpg", Width: 1280},
+			},
+			New: true,
+		},
+		{
+			MediaType: feed.MediaVideo,
+			URL:       "https://example.com/media/erie-2.mp4",
+			ThumbURL:  "https://example.com/media/erie-2-poster.jpg",
+			PubDate:   "Mon, 12 Oct 2026 12:00:00 +0000",
+			Published: at(2),
+			Link:      "https://example.com/erie/2",
+			Source:    "Lake Erie",
+			Alt:       "Time-lapse of clouds over Lake Erie",
+			Width:     1920,
+			Height:    1080,
+		},
+		{
+			MediaType: feed.MediaImage,
+			URL:       "https://example.com/media/superior-1.jpg",
+			ThumbURL:  "https://example.com/media/superior-1.jpg",
+			PubDate:   "Mon, 12 Oct 2026 10:00:00 +0000",
+			Published: at(4),
+			Link:      "https://example.com/superior/1",
+			Source:    "Lake Superior",
+			Author:    "Marquette camera",
+		},
+		{
+			MediaType: feed.MediaImage,
+			URL:       "https://example.com/media/huron-2.jpg",
+			ThumbURL:  "https://example.com/media/huron-2.jpg",
+			PubDate:   "Sun, 11 Oct 2026 14:00:00 +0000",
+			Published: at(24),
+			Link:      "https://example.com/huron/2",
+			Source:    "Lake Huron",
+			Caption:   "🌊 Lake Huron",
+			Color:     "#1e88e5",
+			Cached:    true,
+		},
+		{
+			MediaType: feed.MediaImage,
+			URL:       "https://example.com/media/ontario-1.jpg",
+			ThumbURL:  "https://example.com/media/ontario-1.jpg",
+			PubDate:   "Thu, 08 Oct 2026 14:00:00 +0000",
+			Published: at(96),
+			Link:      "https://example.com/ontario/1",
+			Source:    "Lake Ontario",
+		},
+		{
+			MediaType: feed.MediaImage,
+			URL:       "https://example.com/media/michigan-1.jpg",
+			ThumbURL:  "https://example.com/media/michigan-1.jpg",
+			PubDate:   "sometime yesterday",
+			Link:      "https://example.com/michigan/1",
+			Source:    "Lake Michigan",
+		},
+	}
+}
+
+// goldenOptions returns the page options the golden pages are rendered with.
+func goldenOptions(t *testing.T) pageOptions {
+	t.Helper()
+	loc, err := lookupLocale("en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	theme, err := loadTheme("card")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pageOptions{
+		Title:         "Great Lakes Live Photos",
+		Description:   "Recent photos from the Great Lakes live cameras",
+		SiteURL:       "https://lakes.example.com/",
+		Refresh:       30 * time.Minute,
+		RefreshJitter: time.Minute,
+		Columns:       newColumnLayout(4),
+		Gap:           15,
+		Preload:       2,
+		StaleAfter:    6 * time.Hour,
+		Theme:         theme,
+		Locale:        loc,
+	}
+}
+
+func TestRenderHTMLGolden(t *testing.T) {
+	// Pin everything on the page that depends on when or how lakeview
+	// runs.
+	origNow, origVersion := timeNow, version
+	timeNow, version = func() time.Time { return goldenNow }, "dev"
+	t.Cleanup(func() { timeNow, version = origNow, origVersion })
+
+	photos := goldenPhotos()
+	// Without a srcset, only the thumbnail is shown in the grid, and it
+	// links to the full-size image.
+	thumb := photos[0]
+	thumb.Sizes = nil
+	tests := []struct {
+		name   string
+		photos []feed.Photo
+		opts   func(*pageOptions)
+	}{
+		{name: "empty"},
+		{name: "single", photos: photos[:1]},
+		{name: "thumbnail", photos: []feed.Photo{thumb}},
+		{name: "many", photos: photos},
+		{name: "grouped", photos: photos, opts: func(o *pageOptions) { o.GroupByLake = true }},
+		{name: "chunked", photos: photos, opts: func(o *pageOptions) { o.ChunkSize = 2 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := goldenOptions(t)
+			if tt.opts != nil {
+				tt.opts(&opts)
+			}
+
+			var buf bytes.Buffer
+			if err := renderHTML(&buf, loadTemplate("", opts.Locale), newPageData(tt.photos, opts, goldenNow)); err != nil {
+				t.Fatalf("renderHTML() error = %v", err)
+			}
+
+			path := filepath.Join("testdata", tt.name+".golden.html")
+			if *update {
+				if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("failed to read golden file (run go test -update to create it): %v", err)
+			}
+			if !bytes.Equal(buf.Bytes(), want) {
+				t.Errorf("rendered page differs from %s; if the change is intended, run go test -update and review the diff", path)
+			}
+		})
+	}
+}
+
+func TestFileSinkSkipsUnchanged(t *testing.T) {
+	const contentType = "text/html; charset=utf-8"
+	page := func(generated, photo string) []byte {
+		return []byte(`<p>Last changed <time datetime="` + generated + `">` + generated + `</time></p><img src="` + photo + `">`)
+	}
+	path := filepath.Join(t.TempDir(), "index.html")
+
+	// Each step is applied to the file left by the previous one.
+	steps := []struct {
+		name string
+		sink fileSink
+		body []byte
+		// kept is whether the previous file should be left in place.
+		kept bool
+	}{
+		{name: "new file", body: page("2026-10-12T14:00:00Z", "a.jpg")},
+		{name: "only times changed", body: page("2026-10-12T15:00:00Z", "a.jpg"), kept: true},
+		{name: "forced", sink: fileSink{force: true}, body: page("2026-10-12T16:00:00Z", "a.jpg")},
+		{name: "content changed", body: page("2026-10-12T17:00:00Z", "b.jpg")},
+	}
+	var want []byte
+	for _, step := range steps {
+		if err := step.sink.put(context.Background(), path, contentType, step.body); err != nil {
+			t.Fatalf("%s: put() error = %v", step.name, err)
+		}
+		if !step.kept {
+			want = step.body
+		}
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: file = %s, want %s", step.name, got, want)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"regexp"
+	"strings"
+	"time"
+
+	"lakeview/feed"
+)
+
+// dedupePhotos collapses photos with the same key into the first
+// occurrence, taking the date and link of the earliest-published copy.
+func dedupePhotos(photos []feed.Photo, key func(feed.Photo) string) []feed.Photo {
+	seen := make(map[string]int, len(photos))
+	var deduped []feed.Photo
+
+	for _, p := range photos {
+		k := key(p)
+		i, ok := seen[k]
+		if !ok {
+			seen[k] = len(deduped)
+			deduped = append(deduped, p)
+			continue
+		}
+
+		kept := &deduped[i]
+		if !p.Published.IsZero() && (kept.Published.IsZero() || p.Published.Before(kept.Published)) {
+			kept.PubDate = p.PubDate
+			kept.Published = p.Published
+			kept.Link = p.Link
+		}
+	}
+
+	return deduped
+}
+
+// dedupeKey returns the function that identifies duplicates for dedupePhotos.
+// by is "url" to compare image URLs or "link" to compare post links. When
+// comparing links, a post's images are all kept if allImages is set, matched
+// across feeds by their position in the post; otherwise only the first image
+// of each post is kept.
+func dedupeKey(by string, allImages bool) func(feed.Photo) string {
+	if by != "link" {
+		return func(p feed.Photo) string { return p.URL }
+	}
+	if !allImages {
+		return func(p feed.Photo) string { return p.Link }
+	}
+
+	type post struct{ feedURL, link string }
+	counts := make(map[post]int)
+	return func(p feed.Photo) string {
+		k := post{p.FeedURL, p.Link}
+		counts[k]++
+		return fmt.Sprintf("%s#%d", p.Link, counts[k])
+	}
+}
+
+// shufflePhotos shuffles photos in place. The order depends only on seed and
+// the input order, which SortPhotos makes reproducible.
+func shufflePhotos(photos []feed.Photo, seed uint64) {
+	r := rand.New(rand.NewPCG(seed, seed))
+	r.Shuffle(len(photos), func(i, j int) {
+		photos[i], photos[j] = photos[j], photos[i]
+	})
+}
+
+// filterSince returns the photos published after cutoff. Photos without a
+// parsed date are kept only if keepUndated is set.
+func filterSince(photos []feed.Photo, cutoff time.Time, keepUndated bool) []feed.Photo {
+	var kept []feed.Photo
+	for _, p := range photos {
+		if p.Published.IsZero() {
+			if keepUndated {
+				kept = append(kept, p)
+			}
+			continue
+		}
+		if p.Published.After(cutoff) {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// filterSize returns the photos at least minWidth wide and minHeight tall,
+// and the number dropped. A photo whose size isn't known is kept unless
+// dropUnknown is set.
+func filterSize(photos []feed.Photo, minWidth, minHeight int, dropUnknown bool) ([]feed.Photo, int) {
+	var kept []feed.Photo
+	for _, p := range photos {
+		if fitsSize(p.Width, minWidth, dropUnknown) && fitsSize(p.Height, minHeight, dropUnknown) {
+			kept = append(kept, p)
+		}
+	}
+	return kept, len(photos) - len(kept)
+}
+
+// fitsSize reports whether size, which is 0 if unknown, meets min.
+func fitsSize(size, min int, dropUnknown bool) bool {
+	if min <= 0 {
+		return true
+	}
+	if size == 0 {
+		return !dropUnknown
+	}
+	return size >= min
+}
+
+// applyFeedStyles sets the source name and color of each photo whose feed
+// has a style. Photos whose feed has a display name or emoji are captioned
+// with them, e.g. "🌊 Lake Huron"; the feed's title stands in for a missing
+// name.
+func applyFeedStyles(photos []feed.Photo, styles map[string]feedStyle) {
+	for i := range photos {
+		style, ok := styles[photos[i].FeedURL]
+		if !ok {
+			continue
+		}
+		if style.Name != "" {
+			photos[i].Source = style.Name
+		}
+		photos[i].Color = style.Color
+		if style.Name != "" || style.Emoji != "" {
+			photos[i].Caption = strings.TrimSpace(style.Emoji + " " + photos[i].Source)
+		}
+	}
+}
+
+// textFilter selects photos by the title and description of their posts.
+type textFilter struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+// newTextFilter compiles the include and exclude patterns, which are regular
+// expressions matched case-insensitively unless caseSensitive is set.
+func newTextFilter(include, exclude []string, caseSensitive bool) (textFilter, error) {
+	compile := func(patterns []string) ([]*regexp.Regexp, error) {
+		var res []*regexp.Regexp
+		for _, p := range patterns {
+			if !caseSensitive {
+				p = "(?i)" + p
+			}
+			re, err := regexp.Compile(p)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, re)
+		}
+		return res, nil
+	}
+
+	var tf textFilter
+	var err error
+	if tf.include, err = compile(include); err != nil {
+		return textFilter{}, fmt.Errorf("invalid include pattern: %w", err)
+	}
+	if tf.exclude, err = compile(exclude); err != nil {
+		return textFilter{}, fmt.Errorf("invalid exclude pattern: %w", err)
+	}
+	return tf, nil
+}
+
+// empty reports whether tf has no patterns, so it keeps every photo.
+func (tf textFilter) empty() bool {
+	return len(tf.include) == 0 && len(tf.exclude) == 0
+}
+
+// filter returns the photos whose title or description matches every include
+// pattern and no exclude pattern.
+func (tf textFilter) filter(photos []feed.Photo) []feed.Photo {
+	var kept []feed.Photo
+	for _, p := range photos {
+		if tf.keep(p) {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+func (tf textFilter) keep(p feed.Photo) bool {
+	matches := func(re *regexp.Regexp) bool {
+		return re.MatchString(p.Title) || re.MatchString(p.Description)
+	}
+	for _, re := range tf.exclude {
+		if matches(re) {
+			return false
+		}
+	}
+	for _, re := range tf.include {
+		if !matches(re) {
+			return false
+		}
+	}
+	return true
+}
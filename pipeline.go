@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"lakeview/feed"
+)
+
+// collector fetches photos and filters, sorts, and limits them as the flags
+// ask.
+type collector struct {
+	fetcher *feed.Fetcher
+	// fetch fetches the feeds, or reads the feed given on stdin.
+	fetch  func(context.Context) ([]feed.Photo, feed.Summary)
+	styles map[string]feedStyle
+	strict bool
+
+	filter          textFilter
+	dedupe          bool
+	dedupeBy        string
+	allPostImages   bool
+	minWidth        int
+	minHeight       int
+	dropUnknownSize bool
+	since           time.Duration
+	keepUndated     bool
+	verifyImages    bool
+	concurrency     int
+	limit           int
+
+	// highlightNewFor, cacheDir, and dryRun configure highlightNew.
+	highlightNewFor time.Duration
+	cacheDir        string
+	dryRun          bool
+
+	shuffle   bool
+	seed      uint64
+	sortOrder string
+}
+
+// collect fetches the feeds and returns their photos, ready for output. It
+// fails if ctx is cancelled, if no photos were found, or, when c.strict is
+// set, if any feed failed.
+func (c *collector) collect(ctx context.Context) ([]feed.Photo, feed.Summary, error) {
+	allPhotos, summary := c.fetch(ctx)
+	if err := ctx.Err(); err != nil {
+		return nil, summary, err
+	}
+	slog.Info("Fetched feeds", "succeeded", summary.Succeeded, "failed", summary.Failed)
+	if c.strict && summary.Failed > 0 {
+		return nil, summary, fmt.Errorf("%d of %d feeds failed", summary.Failed, len(summary.Feeds))
+	}
+	if len(allPhotos) == 0 {
+		return nil, summary, errNoPhotos
+	}
+
+	applyFeedStyles(allPhotos, c.styles)
+
+	if !c.filter.empty() {
+		allPhotos = c.filter.filter(allPhotos)
+	}
+
+	if c.dedupe {
+		allPhotos = dedupePhotos(allPhotos, dedupeKey(c.dedupeBy, c.allPostImages))
+	}
+
+	if c.minWidth > 0 || c.minHeight > 0 {
+		var dropped int
+		allPhotos, dropped = filterSize(allPhotos, c.minWidth, c.minHeight, c.dropUnknownSize)
+		slog.Info("Filtered photos by size", "dropped", dropped, "minWidth", c.minWidth, "minHeight", c.minHeight)
+	}
+
+	if c.since > 0 {
+		allPhotos = filterSince(allPhotos, time.Now().Add(-c.since), c.keepUndated)
+	}
+
+	if c.verifyImages {
+		allPhotos = verifyPhotos(ctx, c.fetcher, allPhotos, c.concurrency)
+	}
+
+	feed.SortPhotos(allPhotos)
+
+	if c.limit > 0 && len(allPhotos) > c.limit {
+		allPhotos = allPhotos[:c.limit]
+	}
+
+	if c.highlightNewFor > 0 {
+		if err := highlightNew(allPhotos, c.cacheDir, c.highlightNewFor, c.dryRun); err != nil {
+			slog.Warn("Error highlighting new photos", "error", err)
+		}
+	}
+
+	switch {
+	case c.shuffle:
+		s := c.seed
+		if s == 0 {
+			s = uint64(time.Now().UnixNano())
+		}
+		slog.Info("Shuffling photos", "seed", s)
+		shufflePhotos(allPhotos, s)
+	case c.sortOrder == "oldest":
+		feed.SortPhotosOldestFirst(allPhotos)
+	}
+
+	return allPhotos, summary, nil
+}
+
+// outputWriter writes every output the flags ask for.
+type outputWriter struct {
+	fetcher     *feed.Fetcher
+	sink        outputSink
+	concurrency int
+
+	targets      []outputTarget
+	outputFile   string
+	outputDir    string
+	archiveDir   string
+	manifestFile string
+
+	downloadDir    string
+	inlineImages   bool
+	inlineMaxImage int64
+	inlineMaxTotal int64
+
+	templateFile string
+	loc          *locale
+	pageOpts     pageOptions
+
+	// page and pageSize paginate JSON output.
+	page     int
+	pageSize int
+
+	title       string
+	description string
+	siteURL     string
+}
+
+// write writes every requested output for photos.
+func (o *outputWriter) write(ctx context.Context, allPhotos []feed.Photo, summary feed.Summary) error {
+	if o.downloadDir != "" {
+		// Image paths are relative to the page that references them.
+		baseDir := filepath.Dir(o.targets[0].Path)
+		if o.archiveDir != "" {
+			baseDir = o.archiveDir
+		}
+		stats, err := downloadPhotos(ctx, o.fetcher, allPhotos, o.downloadDir, baseDir, o.concurrency)
+		if err != nil {
+			return fmt.Errorf("failed to download images: %w", err)
+		}
+		slog.Info("Downloaded images", "downloaded", stats.Downloaded, "reused", stats.Reused, "failed", stats.Failed)
+	}
+
+	// Images are only embedded in the HTML page, not the other formats.
+	pagePhotos := allPhotos
+	if o.inlineImages {
+		pagePhotos = slices.Clone(allPhotos)
+		stats := inlinePhotos(ctx, o.fetcher, pagePhotos, o.inlineMaxImage, o.inlineMaxTotal, o.concurrency)
+		if stats.Skipped > 0 {
+			slog.Warn("Reached -inline-max-total; leaving the remaining images remote", "skipped", stats.Skipped, "max", o.inlineMaxTotal)
+		}
+		slog.Info("Embedded images in the page", "inlined", stats.Inlined, "failed", stats.Failed, "bytes", stats.Bytes)
+		if stats.Bytes > inlineWarnSize {
+			slog.Warn("Embedded images make the page large; browsers and mail clients may be slow to open it", "bytes", stats.Bytes)
+		}
+	}
+
+	switch {
+	case o.archiveDir != "":
+		now := time.Now()
+		path, err := archiveGallery(ctx, loadTemplate(o.templateFile, o.loc), newPageData(pagePhotos, o.pageOpts, now), o.loc, o.archiveDir, now)
+		if err != nil {
+			return fmt.Errorf("failed to archive gallery: %w", err)
+		}
+		slog.Info("Generated output successfully", "path", path, "photos", len(allPhotos))
+	case o.outputDir != "":
+		if err := generateHTML(ctx, o.sink, loadTemplate(o.templateFile, o.loc), newPageData(pagePhotos, o.pageOpts, time.Now()), o.outputFile); err != nil {
+			return fmt.Errorf("failed to generate HTML: %w", err)
+		}
+		if err := generateJSON(ctx, o.sink, allPhotos, 1, 0, filepath.Join(o.outputDir, "photos.json")); err != nil {
+			return fmt.Errorf("failed to generate JSON: %w", err)
+		}
+		slog.Info("Generated output successfully", "path", o.outputDir, "photos", len(allPhotos))
+	default:
+		// Every format is written from the same photos, fetched once.
+		for _, target := range o.targets {
+			switch target.Format {
+			case "json":
+				if err := generateJSON(ctx, o.sink, allPhotos, o.page, o.pageSize, target.Path); err != nil {
+					return fmt.Errorf("failed to generate JSON: %w", err)
+				}
+			case "rss":
+				if err := generateRSS(ctx, o.sink, allPhotos, o.title, o.description, o.siteURL, target.Path); err != nil {
+					return fmt.Errorf("failed to generate RSS: %w", err)
+				}
+			default:
+				if err := generateHTML(ctx, o.sink, loadTemplate(o.templateFile, o.loc), newPageData(pagePhotos, o.pageOpts, time.Now()), target.Path); err != nil {
+					return fmt.Errorf("failed to generate HTML: %w", err)
+				}
+			}
+			slog.Info("Generated output successfully", "path", target.Path, "photos", len(allPhotos))
+		}
+	}
+
+	if o.manifestFile != "" {
+		if err := generateManifest(ctx, o.sink, allPhotos, summary, o.title, o.manifestFile); err != nil {
+			return fmt.Errorf("failed to generate manifest: %w", err)
+		}
+		slog.Info("Generated manifest", "path", o.manifestFile)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// s3EnvVar names the environment variable that supplies the -s3 target when
+// the flag isn't set.
+const s3EnvVar = "LAKEVIEW_S3"
+
+// s3Sink uploads output to an S3 bucket, or to another object store with an
+// S3-compatible API. Each output is stored under prefix with the base name of
+// its path, so the default index.html becomes PREFIX/index.html.
+type s3Sink struct {
+	client *s3.Client
+	bucket string
+	prefix string
+}
+
+// newS3Sink returns a sink for target, an s3://BUCKET[/PREFIX] URL.
+// Credentials, and the region if region is empty, come from the standard AWS
+// configuration chain: environment variables, shared config and credentials
+// files, and instance or container roles. If endpoint is set, requests go to
+// it instead of AWS, with path-style bucket addressing as most other object
+// stores expect.
+func newS3Sink(ctx context.Context, target, region, endpoint string) (*s3Sink, error) {
+	bucket, prefix, err := parseS3URL(target)
+	if err != nil {
+		return nil, err
+	}
+
+	var opts []func(*config.LoadOptions) error
+	if region != "" {
+		opts = append(opts, config.WithRegion(region))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	if cfg.Region == "" {
+		return nil, fmt.Errorf("no AWS region configured; use -s3-region or set AWS_REGION")
+	}
+
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+			o.UsePathStyle = true
+		}
+	})
+	return &s3Sink{client: client, bucket: bucket, prefix: prefix}, nil
+}
+
+// parseS3URL splits an s3://BUCKET[/PREFIX] URL into its bucket and prefix.
+func parseS3URL(s string) (bucket, prefix string, err error) {
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme != "s3" || u.Host == "" {
+		return "", "", fmt.Errorf("invalid S3 target %q; want s3://BUCKET[/PREFIX]", s)
+	}
+	return u.Host, strings.Trim(u.Path, "/"), nil
+}
+
+// key returns the object key for the output at p.
+func (s *s3Sink) key(p string) string {
+	return path.Join(s.prefix, filepath.Base(p))
+}
+
+func (s *s3Sink) put(ctx context.Context, p, contentType string, body []byte) error {
+	key := s.key(p)
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload s3://%s/%s: %w", s.bucket, key, err)
+	}
+	slog.Info("Uploaded output", "bucket", s.bucket, "key", key, "bytes", len(body))
+	return nil
+}
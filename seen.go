@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"lakeview/feed"
+)
+
+// seenFile is the file in -cache-dir that records when each photo was first
+// seen, for -highlight-new.
+const seenFile = "seen.json"
+
+// seenRetention is how long a photo that has dropped out of the gallery is
+// remembered, so that it isn't highlighted again if it comes back.
+const seenRetention = 7 * 24 * time.Hour
+
+// seenPhoto records when a photo, identified by its URL, was first and last
+// in the gallery.
+type seenPhoto struct {
+	First time.Time `json:"first"`
+	Last  time.Time `json:"last"`
+}
+
+// loadSeen reads the seen photos from path. It returns nil, and no error, if
+// the file doesn't exist yet.
+func loadSeen(path string) (map[string]seenPhoto, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read seen photos: %w", err)
+	}
+
+	seen := make(map[string]seenPhoto)
+	if err := json.Unmarshal(data, &seen); err != nil {
+		return nil, fmt.Errorf("failed to parse seen photos: %w", err)
+	}
+	return seen, nil
+}
+
+// saveSeen writes the seen photos to path.
+func saveSeen(path string, seen map[string]seenPhoto) error {
+	return writeFileAtomic(path, func(w io.Writer) error {
+		if err := json.NewEncoder(w).Encode(seen); err != nil {
+			return fmt.Errorf("failed to encode seen photos: %w", err)
+		}
+		return nil
+	})
+}
+
+// markNew sets New on the photos first seen within window of now, and
+// returns seen updated with photos. If seen is nil, there was no previous
+// run to compare against, so no photo is marked. Photos last seen more than
+// seenRetention ago are forgotten.
+func markNew(photos []feed.Photo, seen map[string]seenPhoto, now time.Time, window time.Duration) map[string]seenPhoto {
+	firstRun := seen == nil
+	updated := make(map[string]seenPhoto, len(photos))
+	for url, s := range seen {
+		if now.Sub(s.Last) <= seenRetention {
+			updated[url] = s
+		}
+	}
+
+	for i, p := range photos {
+		s, ok := updated[p.URL]
+		if !ok {
+			s.First = now
+		}
+		s.Last = now
+		updated[p.URL] = s
+
+		photos[i].New = !firstRun && now.Sub(s.First) < window
+	}
+	return updated
+}
+
+// highlightNew marks the photos first seen within window, using and updating
+// the state in cacheDir. The state is only read, not updated, if dryRun is
+// set.
+func highlightNew(photos []feed.Photo, cacheDir string, window time.Duration, dryRun bool) error {
+	path := filepath.Join(cacheDir, seenFile)
+	seen, err := loadSeen(path)
+	if err != nil {
+		return err
+	}
+
+	seen = markNew(photos, seen, time.Now(), window)
+	if dryRun {
+		return nil
+	}
+	return saveSeen(path, seen)
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"lakeview/feed"
+)
+
+// galleryPage is a generated gallery page, with the photos on it and when it
+// was generated.
+type galleryPage struct {
+	page      []byte
+	photos    []feed.Photo
+	generated time.Time
+}
+
+// gallery serves the most recently generated page from memory, with an ETag
+// derived from its content so that clients can revalidate it. A request
+// whose query string overrides the layout gets a page rendered with render
+// from the same photos, as of the same generation time.
+type gallery struct {
+	render func([]feed.Photo, time.Time, layoutQuery) ([]byte, error)
+
+	mu        sync.RWMutex
+	page      []byte
+	photos    []feed.Photo
+	generated time.Time
+	etag      string
+	// modified is when the page's content last changed.
+	modified time.Time
+}
+
+// set replaces the page and the photos it shows. Its modification time only
+// changes if its content did.
+func (g *gallery) set(p galleryPage) {
+	etag := pageETag(p.page)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.photos = p.photos
+	g.generated = p.generated
+	if etag == g.etag {
+		return
+	}
+	g.page = p.page
+	g.etag = etag
+	g.modified = time.Now()
+}
+
+// pageETag returns a strong ETag for page.
+func pageETag(page []byte) string {
+	sum := sha256.Sum256(page)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func (g *gallery) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" && r.URL.Path != "/index.html" {
+		http.NotFound(w, r)
+		return
+	}
+
+	lq, err := parseLayoutQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	g.mu.RLock()
+	page, photos, generated, etag, modified := g.page, g.photos, g.generated, g.etag, g.modified
+	g.mu.RUnlock()
+
+	if !lq.empty() {
+		if page, err = g.render(photos, generated, lq); err != nil {
+			slog.Warn("Error rendering gallery", "query", r.URL.RawQuery, "error", err)
+			http.Error(w, "Error rendering gallery", http.StatusInternalServerError)
+			return
+		}
+		etag = pageETag(page)
+	}
+
+	// Clients may store the page but must revalidate it on every use, which
+	// ServeContent answers with a 304 if it hasn't changed.
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, no-cache")
+	w.Header().Set("ETag", etag)
+	http.ServeContent(w, r, "index.html", modified, bytes.NewReader(page))
+}
+
+// maxQueryColumns and maxQueryGap are the largest column count and gap, in
+// pixels, that a request can ask for; larger values are clamped.
+const (
+	maxQueryColumns = 12
+	maxQueryGap     = 100
+)
+
+// layoutQuery is the layout requested in a served page's query string, as
+// ?columns=3&gap=20. Either may be left out to keep the configured value.
+type layoutQuery struct {
+	columns int
+	gap     int
+	hasGap  bool
+}
+
+// parseLayoutQuery parses the layout parameters in q, clamping them to
+// 1..maxQueryColumns columns and a gap of 0..maxQueryGap pixels. Other
+// parameters are ignored.
+func parseLayoutQuery(q url.Values) (layoutQuery, error) {
+	var lq layoutQuery
+	if s := q.Get("columns"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return layoutQuery{}, fmt.Errorf("invalid columns %q", s)
+		}
+		lq.columns = min(max(n, 1), maxQueryColumns)
+	}
+	if s := q.Get("gap"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return layoutQuery{}, fmt.Errorf("invalid gap %q", s)
+		}
+		lq.gap = min(max(n, 0), maxQueryGap)
+		lq.hasGap = true
+	}
+	return lq, nil
+}
+
+// empty reports whether lq keeps the configured layout.
+func (lq layoutQuery) empty() bool {
+	return lq.columns == 0 && !lq.hasGap
+}
+
+// apply returns opts with lq's overrides. Overriding the column count
+// replaces any custom breakpoints with the default ones scaled to it.
+func (lq layoutQuery) apply(opts pageOptions) pageOptions {
+	if lq.columns > 0 {
+		opts.Columns = newColumnLayout(lq.columns)
+	}
+	if lq.hasGap {
+		opts.Gap = lq.gap
+	}
+	return opts
+}
+
+// shutdownTimeout bounds how long serve waits for in-flight requests when
+// shutting down.
+const shutdownTimeout = 10 * time.Second
+
+// serve generates the gallery once, then serves it on addr while
+// regenerating it every interval. If a regeneration fails, the previous page
+// keeps being served. generate returns the page; render re-renders its
+// photos for a request that overrides the layout. When ctx is cancelled,
+// serve stops regenerating, waits for an in-progress generation and
+// in-flight requests to finish, and returns nil. generate is passed ctx so
+// that an in-progress generation is cancelled too. If the server fails, for
+// instance because addr is in use, serve stops regenerating and returns the
+// error.
+func serve(ctx context.Context, addr string, interval time.Duration, generate func(context.Context) (galleryPage, error), render func([]feed.Photo, time.Time, layoutQuery) ([]byte, error)) error {
+	p, err := generate(ctx)
+	if err != nil {
+		return err
+	}
+
+	g := &gallery{render: render}
+	g.set(p)
+
+	// Regeneration stops whenever serve returns, including when the server
+	// fails to start, so that waiting for it doesn't hang.
+	ctx, stop := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer func() {
+		stop()
+		wg.Wait()
+	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			p, err := generate(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				slog.Warn("Error regenerating gallery", "error", err)
+				continue
+			}
+			g.set(p)
+			slog.Info("Regenerated gallery", "bytes", len(p.page))
+		}
+	}()
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.Handle("/", g)
+	srv := &http.Server{Addr: addr, Handler: mux}
+
+	errc := make(chan error, 1)
+	go func() {
+		slog.Info("Serving gallery", "addr", addr)
+		errc <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shut down server: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestServeListenError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	generate := func(context.Context) (galleryPage, error) {
+		return galleryPage{page: []byte("<html></html>"), generated: time.Now()}, nil
+	}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- serve(context.Background(), ln.Addr().String(), time.Millisecond, generate, nil)
+	}()
+
+	select {
+	case err := <-errc:
+		if err == nil {
+			t.Error("serve() = nil, want an error for an address in use")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve() didn't return after failing to listen")
+	}
+}
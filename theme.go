@@ -0,0 +1,40 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+//go:embed themes/*.css
+var themeFiles embed.FS
+
+// themeNames returns the names of the built-in themes, sorted.
+func themeNames() []string {
+	matches, _ := fs.Glob(themeFiles, "themes/*.css")
+	names := make([]string, len(matches))
+	for i, m := range matches {
+		names[i] = strings.TrimSuffix(path.Base(m), ".css")
+	}
+	return names
+}
+
+// loadTheme returns the CSS of the built-in theme name, indented to match the
+// template's <style> element.
+func loadTheme(name string) (template.CSS, error) {
+	data, err := themeFiles.ReadFile("themes/" + name + ".css")
+	if err != nil {
+		return "", fmt.Errorf("unknown theme %q (available: %s)", name, strings.Join(themeNames(), ", "))
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	for i := 1; i < len(lines); i++ {
+		if lines[i] != "" {
+			lines[i] = "        " + lines[i]
+		}
+	}
+	return template.CSS(strings.Join(lines, "\n")), nil
+}
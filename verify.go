@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+
+	"lakeview/feed"
+)
+
+// verifyPhotos drops photos whose image URL doesn't respond to a HEAD
+// request with a 2xx status and an image content type. At most concurrency
+// requests are made at once. The order of the remaining photos is preserved.
+func verifyPhotos(ctx context.Context, f *feed.Fetcher, photos []feed.Photo, concurrency int) []feed.Photo {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	ok := make([]bool, len(photos))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				err := verifyImage(ctx, f, photos[i])
+				if err != nil {
+					slog.Debug("Dropping unverified image", "url", photos[i].URL, "error", err)
+					continue
+				}
+				ok[i] = true
+			}
+		}()
+	}
+	for i := range photos {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	var verified []feed.Photo
+	for i, p := range photos {
+		if ok[i] {
+			verified = append(verified, p)
+		}
+	}
+
+	if dropped := len(photos) - len(verified); dropped > 0 {
+		slog.Info("Dropped photos that failed verification", "dropped", dropped)
+	}
+	return verified
+}
+
+// verifyImage checks that p's URL is reachable and serves an image, or a
+// video if p is one.
+func verifyImage(ctx context.Context, f *feed.Fetcher, p feed.Photo) error {
+	req, err := f.NewRequest(ctx, http.MethodHead, p.URL)
+	if err != nil {
+		return err
+	}
+
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch image: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &feed.StatusError{URL: p.URL, StatusCode: resp.StatusCode}
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, p.MediaType+"/") {
+		return fmt.Errorf("unexpected content type %q", ct)
+	}
+	return nil
+}
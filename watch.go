@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"log/slog"
+	"slices"
+	"time"
+
+	"lakeview/feed"
+)
+
+// watchFeeds collects photos every interval until ctx is cancelled, calling
+// write only when the photos differ from those last written. Errors are
+// logged and the previous output is left in place.
+func watchFeeds(ctx context.Context, interval time.Duration,
+	collect func(context.Context) ([]feed.Photo, feed.Summary, error),
+	write func(context.Context, []feed.Photo, feed.Summary) error) {
+	var last [sha256.Size]byte
+	written := false
+
+	for {
+		start := time.Now()
+		photos, summary, err := collect(ctx)
+		switch {
+		case ctx.Err() != nil:
+		case err != nil:
+			slog.Warn("Error collecting photos", "error", err)
+		default:
+			sum := photosHash(photos)
+			if written && sum == last {
+				slog.Info("Photos unchanged; not rewriting", "photos", len(photos), "elapsed", time.Since(start))
+				break
+			}
+			if err := write(ctx, photos, summary); err != nil {
+				slog.Warn("Error writing output", "error", err)
+				break
+			}
+			last, written = sum, true
+			slog.Info("Photos changed; output rewritten", "photos", len(photos), "elapsed", time.Since(start))
+		}
+
+		select {
+		case <-ctx.Done():
+			slog.Info("Stopping watch")
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// photosHash returns a hash of photos that doesn't depend on their order, so
+// that a shuffled gallery isn't rewritten unless its photos changed.
+func photosHash(photos []feed.Photo) [sha256.Size]byte {
+	sorted := slices.Clone(photos)
+	feed.SortPhotos(sorted)
+	data, _ := json.Marshal(sorted)
+	return sha256.Sum256(data)
+}